@@ -3,32 +3,77 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	_ "net/http/pprof"
 	"strings"
 
-	"net/url"
 	"strconv"
 	"time"
 
 	"axora/config"
 	"axora/crawler"
 	"axora/pkg/embedding"
+	"axora/pkg/httpclient"
 	qdrantClient "axora/pkg/qdrantdb"
 
 	"go.uber.org/zap"
 )
 
+// CrawlRequest holds the crawl settings shared by /seed and /browse
+type CrawlRequest struct {
+	Topic          string                  `json:"topic"`
+	ChunkingMethod string                  `json:"chunking_method"`
+	SameDomainOnly bool                    `json:"same_domain_only"`
+	QualityWeights *crawler.QualityWeights `json:"quality_weights"`
+	ProxyURL       string                  `json:"proxy_url"`
+}
+
 type SeedRequest struct {
-	Topic          string `json:"topic"`
-	ChunkingMethod string `json:"chunking_method"`
+	CrawlRequest
 }
 
 type BrowseRequest struct {
-	Topic          string `json:"topic"`
-	ChunkingMethod string `json:"chunking_method"`
+	CrawlRequest
+	ParallelEngines bool `json:"parallel_engines"`
+}
+
+// validateCrawlRequest rejects settings the crawl would otherwise trip over
+// on every page
+func validateCrawlRequest(req CrawlRequest) error {
+	// every page is checked against the topic, an empty one would drop them all
+	if strings.TrimSpace(req.Topic) == "" {
+		return errors.New("missing topic parameter")
+	}
+	if strings.TrimSpace(req.ChunkingMethod) == "" {
+		return errors.New("missing chunking_method parameter")
+	}
+	if err := crawler.ValidateChunkMethod(req.ChunkingMethod); err != nil {
+		return fmt.Errorf("invalid chunking_method: %w", err)
+	}
+	if req.QualityWeights != nil {
+		if _, err := req.QualityWeights.Normalize(); err != nil {
+			return fmt.Errorf("invalid quality_weights: %w", err)
+		}
+	}
+	if req.ProxyURL != "" {
+		if err := crawler.ValidateProxyURL(req.ProxyURL); err != nil {
+			return fmt.Errorf("invalid proxy_url: %w", err)
+		}
+	}
+	return nil
+}
+
+func (req CrawlRequest) crawlConfig() crawler.CrawlConfig {
+	return crawler.CrawlConfig{
+		ChunkMethod:    req.ChunkingMethod,
+		Topic:          req.Topic,
+		SameDomainOnly: req.SameDomainOnly,
+		QualityWeights: req.QualityWeights,
+		ProxyURL:       req.ProxyURL,
+	}
 }
 
 func main() {
@@ -60,21 +105,72 @@ func main() {
 	// =========
 	// Chromedp
 	// =========
-	browser := crawler.NewBrowser(logger, cfg.ProxyURL)
+	screenshotDir := ""
+	if cfg.BrowserDebug {
+		screenshotDir = cfg.ScreenshotDir
+	}
+	browser := crawler.NewBrowser(logger, cfg.ProxyURL, screenshotDir)
+	browser.ResultFilter = crawler.HostFilter{
+		Include: cfg.SearchIncludeHosts,
+		Exclude: cfg.SearchExcludeHosts,
+	}
+	browser.ExecPath = cfg.ChromeExecPath
+	browser.RemoteURL = cfg.ChromeRemoteURL
 
 	// =========
 	// HTTP
 	// =========
-	httpClient, httpTransport := NewHttpClient(cfg.ProxyURL)
+	crawlHTTPConfig := httpclient.DefaultConfig()
+	crawlHTTPConfig.ProxyURL = cfg.ProxyURL
+	crawlHTTPConfig.Timeout = 5 * time.Minute
+	crawlHTTPConfig.ResponseHeaderTimeout = 2 * time.Minute
+	if cfg.HTTPDebug {
+		crawlHTTPConfig.Logger = logger
+	}
+	// each crawl builds its own client from crawlHTTPConfig, the search
+	// client only shares the settings
+	searchHTTPClient, _, errHTTP := httpclient.New(crawlHTTPConfig)
+	if errHTTP != nil {
+		logger.Fatal("Failed to initialize http client", zap.Error(errHTTP))
+	}
+
+	// =========
+	// Proxy check
+	// =========
+	// log the IP crawls leave from, so a proxy that is not routing is noticed
+	// at startup rather than in the crawled sites' rate limits
+	if cfg.ProxyURL != "" {
+		ipCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		ip, err := crawler.GetPublicIP(ipCtx, searchHTTPClient, cfg.IPCheckEndpoints)
+		cancel()
+		if err != nil {
+			logger.Warn("Failed to check the public IP through the proxy", zap.Error(err))
+		} else {
+			logger.Info("Crawling through proxy", zap.String("public_ip", ip))
+		}
+	}
+
+	// =========
+	// Search backend
+	// =========
+	if err := crawler.ValidateSearchBackend(cfg.SearchBackend); err != nil {
+		logger.Fatal("Invalid search backend", zap.Error(err))
+	}
+	browser.SearchBackend = cfg.SearchBackend
+	browser.HTTPSearch = crawler.NewHTTPSearch(logger, searchHTTPClient, 5, 2*time.Second)
 
 	// =========
 	// Qdrant vector
 	// =========
-	qdb, errQdrant := qdrantClient.NewClient(cfg.QdrantHost, cfg.QdrantPort)
+	qdb, errQdrant := qdrantClient.NewClient(cfg.QdrantHost, cfg.QdrantPort,
+		cfg.QdrantVectorSize, cfg.QdrantDistance, qdrantClient.PayloadConfig{
+			ContentMode:   cfg.QdrantContentMode,
+			MaxContentLen: cfg.QdrantMaxContentLen,
+		})
 	if errQdrant != nil {
 		logger.Error("Failed to initialize qdrant", zap.Error(errQdrant))
 	}
-	err := qdb.CreateCrawlCollection(context.Background())
+	err := qdb.CreateCollection(context.Background())
 	if err != nil {
 		logger.Error("Failed to initialize crawl collection", zap.Error(err))
 	}
@@ -82,29 +178,62 @@ func main() {
 	// =========
 	// Embedding Client
 	// =========
-	embeddingClient := embedding.NewMpnetBaseV2(cfg.MpnetBaseV2Url)
+	// the embedding service is internal, so it is reached without the proxy
+	embeddingHTTPConfig := httpclient.DefaultConfig()
+	if cfg.HTTPDebug {
+		embeddingHTTPConfig.Logger = logger
+	}
+	embeddingHTTPClient, _, errEmbedHTTP := httpclient.New(embeddingHTTPConfig)
+	if errEmbedHTTP != nil {
+		logger.Fatal("Failed to initialize embedding http client", zap.Error(errEmbedHTTP))
+	}
+	embeddingClient := embedding.NewMpnetBaseV2(cfg.MpnetBaseV2Url, embeddingHTTPClient)
 
 	// =========
 	// Chunking Client
 	// =========
-	chunkingClient, errChunk := crawler.NewChunker(cfg.MaxEmbedModelTokenSize, embeddingClient,
+	chunkingClient, errChunk := crawler.NewChunker(cfg.MaxEmbedModelTokenSize, cfg.MinChunkTokens, embeddingClient,
 		logger, cfg.TokenizerFilePath)
 	if errChunk != nil {
 		logger.Error("Failed to initialize chunk client", zap.Error(errChunk))
 	}
 
+	// =========
+	// Dead letter sink
+	// =========
+	var deadLetters *crawler.DeadLetterSink
+	if cfg.DeadLetterPath != "" {
+		var errDeadLetter error
+		deadLetters, errDeadLetter = crawler.NewDeadLetterSink(cfg.DeadLetterPath, cfg.DeadLetterIncludeBody)
+		if errDeadLetter != nil {
+			logger.Error("Failed to initialize dead letter sink", zap.Error(errDeadLetter))
+		} else {
+			defer deadLetters.Close()
+		}
+	}
+
 	// =========
 	// Crawler Service
 	// =========
 	crawlerInstance, errCrawl := crawler.NewCrawler(
-		cfg.ProxyURL,
-		httpClient,
-		httpTransport,
+		crawlHTTPConfig,
 		logger,
 		qdb,
 		chunkingClient,
 		domains.Domains,
 		cfg.BoltDBPath,
+		cfg.MaxLinksPerPage,
+		cfg.MatchRegisteredDomain,
+		deadLetters,
+		crawler.QualityConfig{
+			StripBoilerplate: cfg.StripBoilerplate,
+			MinScore:         cfg.QualityMinScore,
+			Weights: crawler.QualityWeights{
+				Length:   cfg.QualityWeightLength,
+				Richness: cfg.QualityWeightRichness,
+				Sentence: cfg.QualityWeightSentence,
+			},
+		},
 	)
 	if errCrawl != nil {
 		logger.Error("Failed to initialize crawl", zap.Error(errCrawl))
@@ -126,15 +255,15 @@ func main() {
 		}
 		defer r.Body.Close()
 
-		if strings.TrimSpace(req.ChunkingMethod) == "" {
-			http.Error(w, "missing chunking_method parameter", http.StatusBadRequest)
+		if err := validateCrawlRequest(req.CrawlRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		ch := make(chan string)
 
 		go func() {
-			err := crawlerInstance.Crawl(ch, req.ChunkingMethod, req.Topic)
+			err := crawlerInstance.Crawl(context.Background(), ch, req.crawlConfig())
 			if err != nil {
 				logger.Error("crawl error", zap.Error(err))
 			}
@@ -162,25 +291,37 @@ func main() {
 		}
 		defer r.Body.Close()
 
-		if strings.TrimSpace(req.Topic) == "" {
-			http.Error(w, "missing topic parameter", http.StatusBadRequest)
-			return
-		}
-		if strings.TrimSpace(req.ChunkingMethod) == "" {
-			http.Error(w, "missing chunking_method parameter", http.StatusBadRequest)
+		if err := validateCrawlRequest(req.CrawlRequest); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
 		}
 
 		ch := make(chan string, 100)
 
 		go func() {
-			err := crawlerInstance.Crawl(ch, req.ChunkingMethod, req.Topic)
+			err := crawlerInstance.Crawl(context.Background(), ch, req.crawlConfig())
 			if err != nil {
 				logger.Error("crawl error", zap.Error(err))
 			}
 		}()
 
-		browser.CollectUrls(req.Topic, ch)
+		var errCollect error
+		if req.ParallelEngines {
+			errCollect = browser.CollectUrlsParallel(context.Background(), req.Topic,
+				browser.SupportedEngines, ch)
+		} else {
+			errCollect = browser.CollectUrls(context.Background(), req.Topic, ch)
+		}
+		if err := errCollect; err != nil {
+			var blocked *crawler.BlockedError
+			if errors.As(err, &blocked) {
+				logger.Warn("search engine blocked the browser",
+					zap.String("engine", blocked.Engine),
+					zap.String("reason", blocked.Reason))
+			} else {
+				logger.Error("collect urls error", zap.Error(err))
+			}
+		}
 		close(ch)
 
 		w.WriteHeader(http.StatusOK)
@@ -189,27 +330,11 @@ func main() {
 
 	http.HandleFunc("/seed", seedh)
 	http.HandleFunc("/browse", browseh)
+	http.HandleFunc("/vectors/{id}", newVectorHandler(qdb, logger))
+	http.HandleFunc("/vectors/{id}/similar", newSimilarHandler(qdb, logger))
 
 	fmt.Println("start")
 	if err := http.ListenAndServe(":"+strconv.Itoa(cfg.AppPort), nil); err != nil {
 		logger.Error("HTTP server failed", zap.Error(err))
 	}
 }
-
-func NewHttpClient(proxyUrl string) (*http.Client, *http.Transport) {
-	proxyURL, _ := url.Parse(proxyUrl)
-	transport := &http.Transport{
-		Proxy:                 http.ProxyURL(proxyURL),
-		IdleConnTimeout:       90 * time.Second,
-		MaxIdleConns:          100,
-		MaxIdleConnsPerHost:   10,
-		ResponseHeaderTimeout: 120 * time.Second,
-	}
-
-	client := &http.Client{
-		Transport: transport,
-		Timeout:   5 * time.Minute,
-	}
-
-	return client, transport
-}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"axora/crawler"
+)
+
+func TestValidateCrawlRequest(t *testing.T) {
+	valid := CrawlRequest{Topic: "golang", ChunkingMethod: crawler.ChunkMarkdown}
+
+	tests := []struct {
+		name    string
+		modify  func(*CrawlRequest)
+		wantErr string
+	}{
+		{name: "valid", modify: func(*CrawlRequest) {}},
+		{name: "missing topic", modify: func(r *CrawlRequest) { r.Topic = "  " }, wantErr: "missing topic"},
+		{name: "missing chunking method", modify: func(r *CrawlRequest) { r.ChunkingMethod = "" }, wantErr: "missing chunking_method"},
+		{name: "unknown chunking method", modify: func(r *CrawlRequest) { r.ChunkingMethod = "words" }, wantErr: "invalid chunking_method"},
+		{
+			name:    "negative weight",
+			modify:  func(r *CrawlRequest) { r.QualityWeights = &crawler.QualityWeights{Length: -1, Richness: 1} },
+			wantErr: "invalid quality_weights",
+		},
+		{
+			name:   "valid weights",
+			modify: func(r *CrawlRequest) { r.QualityWeights = &crawler.QualityWeights{Length: 2, Richness: 1, Sentence: 1} },
+		},
+		{name: "bad proxy scheme", modify: func(r *CrawlRequest) { r.ProxyURL = "ftp://proxy:21" }, wantErr: "invalid proxy_url"},
+		{name: "valid proxy", modify: func(r *CrawlRequest) { r.ProxyURL = "socks5://proxy:1080" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := valid
+			tt.modify(&req)
+			err := validateCrawlRequest(req)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
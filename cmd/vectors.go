@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"axora/crawler"
+
+	"go.uber.org/zap"
+)
+
+// newVectorHandler serves GET /vectors/{id}, the stored chunk with that ID
+func newVectorHandler(store crawler.VectorStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		point, err := store.GetPoint(r.Context(), r.PathValue("id"))
+		if errors.Is(err, crawler.ErrPointNotFound) {
+			http.Error(w, "vector not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("get vector error", zap.String("id", r.PathValue("id")), zap.Error(err))
+			http.Error(w, "failed to get vector", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(point)
+	}
+}
+
+// newSimilarHandler serves GET /vectors/{id}/similar, the topK chunks closest
+// to the one with that ID, excluding itself
+func newSimilarHandler(store crawler.VectorStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		topK := 10
+		if v := r.URL.Query().Get("topK"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n <= 0 {
+				http.Error(w, "topK must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			topK = n
+		}
+
+		id := r.PathValue("id")
+		point, err := store.GetPoint(r.Context(), id)
+		if errors.Is(err, crawler.ErrPointNotFound) {
+			http.Error(w, "vector not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("get vector error", zap.String("id", id), zap.Error(err))
+			http.Error(w, "failed to get vector", http.StatusInternalServerError)
+			return
+		}
+
+		hits, err := store.Search(r.Context(), point.ContentEmbedding, topK, crawler.WithExcludeIDs(id))
+		if err != nil {
+			logger.Error("similar search error", zap.String("id", id), zap.Error(err))
+			http.Error(w, "failed to search similar vectors", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(hits)
+	}
+}
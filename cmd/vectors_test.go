@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"axora/crawler"
+
+	"go.uber.org/zap"
+)
+
+// fakeStore serves points from a map and records the last search
+type fakeStore struct {
+	crawler.VectorStore // only GetPoint and Search are called
+
+	points    map[string]*crawler.StoredPoint
+	getErr    error
+	searchErr error
+
+	searched *crawler.SearchOptions
+	topK     int
+	vector   []float32
+}
+
+func (f *fakeStore) GetPoint(_ context.Context, id string) (*crawler.StoredPoint, error) {
+	if f.getErr != nil {
+		return nil, f.getErr
+	}
+	if p, ok := f.points[id]; ok {
+		return p, nil
+	}
+	return nil, crawler.ErrPointNotFound
+}
+
+func (f *fakeStore) Search(_ context.Context, vector []float32, topK int, opts ...crawler.SearchOption) ([]crawler.SearchHit, error) {
+	if f.searchErr != nil {
+		return nil, f.searchErr
+	}
+	f.searched = &crawler.SearchOptions{}
+	for _, opt := range opts {
+		opt(f.searched)
+	}
+	f.topK, f.vector = topK, vector
+	return []crawler.SearchHit{{ID: "b", URL: "https://example.com/b", Score: 0.9}}, nil
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{points: map[string]*crawler.StoredPoint{
+		"a": {ID: "a", CrawlVectorDoc: crawler.CrawlVectorDoc{
+			URL:              "https://example.com/a",
+			ContentEmbedding: []float32{0.1, 0.2},
+		}},
+	}}
+}
+
+// serve routes target through a mux with the same patterns as main
+func serve(store crawler.VectorStore, method, target string) *httptest.ResponseRecorder {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vectors/{id}", newVectorHandler(store, zap.NewNop()))
+	mux.HandleFunc("/vectors/{id}/similar", newSimilarHandler(store, zap.NewNop()))
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestVectorHandler(t *testing.T) {
+	rec := serve(newFakeStore(), http.MethodGet, "/vectors/a")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var point crawler.StoredPoint
+	if err := json.NewDecoder(rec.Body).Decode(&point); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if point.ID != "a" || point.URL != "https://example.com/a" || len(point.ContentEmbedding) != 2 {
+		t.Fatalf("point = %+v", point)
+	}
+
+	failing := newFakeStore()
+	failing.getErr = errors.New("qdrant unavailable")
+	tests := []struct {
+		name   string
+		store  *fakeStore
+		method string
+		target string
+		want   int
+	}{
+		{"unknown id", newFakeStore(), http.MethodGet, "/vectors/missing", http.StatusNotFound},
+		{"wrong method", newFakeStore(), http.MethodPost, "/vectors/a", http.StatusMethodNotAllowed},
+		{"store error", failing, http.MethodGet, "/vectors/a", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if rec := serve(tt.store, tt.method, tt.target); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
+
+func TestSimilarHandler(t *testing.T) {
+	store := newFakeStore()
+	rec := serve(store, http.MethodGet, "/vectors/a/similar?topK=3")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body)
+	}
+	var hits []crawler.SearchHit
+	if err := json.NewDecoder(rec.Body).Decode(&hits); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != "b" {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if store.topK != 3 || len(store.vector) != 2 {
+		t.Fatalf("searched topK %d with vector %v, want 3 and the stored embedding", store.topK, store.vector)
+	}
+	if ids := store.searched.ExcludeIDs; len(ids) != 1 || ids[0] != "a" {
+		t.Fatalf("excluded %v, want the queried id", ids)
+	}
+
+	store = newFakeStore()
+	serve(store, http.MethodGet, "/vectors/a/similar")
+	if store.topK != 10 {
+		t.Fatalf("default topK = %d, want 10", store.topK)
+	}
+
+	failing := newFakeStore()
+	failing.searchErr = errors.New("qdrant unavailable")
+	tests := []struct {
+		name   string
+		store  *fakeStore
+		method string
+		target string
+		want   int
+	}{
+		{"zero topK", newFakeStore(), http.MethodGet, "/vectors/a/similar?topK=0", http.StatusBadRequest},
+		{"non numeric topK", newFakeStore(), http.MethodGet, "/vectors/a/similar?topK=many", http.StatusBadRequest},
+		{"unknown id", newFakeStore(), http.MethodGet, "/vectors/missing/similar", http.StatusNotFound},
+		{"wrong method", newFakeStore(), http.MethodDelete, "/vectors/a/similar", http.StatusMethodNotAllowed},
+		{"search error", failing, http.MethodGet, "/vectors/a/similar", http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if rec := serve(tt.store, tt.method, tt.target); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,9 +18,32 @@ type Config struct {
 	EmbedModelID           string
 	TokenizerFilePath      string
 	BoltDBPath             string
+	DeadLetterPath         string
+	QdrantDistance         string
+	QdrantContentMode      string
+	ScreenshotDir          string
+	SearchBackend          string
+	ChromeExecPath         string
+	ChromeRemoteURL        string
 	QdrantPort             int
+	QdrantVectorSize       int
+	QdrantMaxContentLen    int
 	MaxEmbedModelTokenSize int
+	MinChunkTokens         int
 	AppPort                int
+	MaxLinksPerPage        int
+	MatchRegisteredDomain  bool
+	DeadLetterIncludeBody  bool
+	StripBoilerplate       bool
+	QualityMinScore        float64
+	QualityWeightLength    float64
+	QualityWeightRichness  float64
+	QualityWeightSentence  float64
+	SearchIncludeHosts     []string
+	SearchExcludeHosts     []string
+	IPCheckEndpoints       []string
+	HTTPDebug              bool
+	BrowserDebug           bool
 }
 
 func Load() (*Config, error) {
@@ -31,10 +55,62 @@ func Load() (*Config, error) {
 	if err != nil {
 		return nil, err
 	}
+	qdrantVectorSize, err := strconv.Atoi(getEnvOrDefault("QDRANT_VECTOR_SIZE", "768"))
+	if err != nil {
+		return nil, err
+	}
+	qdrantMaxContentLen, err := strconv.Atoi(getEnvOrDefault("QDRANT_MAX_CONTENT_LEN", "0"))
+	if err != nil {
+		return nil, err
+	}
 	tokenSize, err := strconv.Atoi(getEnv("MAX_EMBED_MODEL_TOKEN_SIZE"))
 	if err != nil {
 		return nil, err
 	}
+	minChunkTokens, err := strconv.Atoi(getEnvOrDefault("MIN_CHUNK_TOKENS", "75"))
+	if err != nil {
+		return nil, err
+	}
+	maxLinksPerPage, err := strconv.Atoi(getEnvOrDefault("MAX_LINKS_PER_PAGE", "0"))
+	if err != nil {
+		return nil, err
+	}
+	matchRegisteredDomain, err := strconv.ParseBool(getEnvOrDefault("MATCH_REGISTERED_DOMAIN", "false"))
+	if err != nil {
+		return nil, err
+	}
+	deadLetterIncludeBody, err := strconv.ParseBool(getEnvOrDefault("DEAD_LETTER_INCLUDE_BODY", "false"))
+	if err != nil {
+		return nil, err
+	}
+	stripBoilerplate, err := strconv.ParseBool(getEnvOrDefault("STRIP_BOILERPLATE", "false"))
+	if err != nil {
+		return nil, err
+	}
+	qualityMinScore, err := strconv.ParseFloat(getEnvOrDefault("QUALITY_MIN_SCORE", "67"), 64)
+	if err != nil {
+		return nil, err
+	}
+	qualityWeightLength, err := strconv.ParseFloat(getEnvOrDefault("QUALITY_WEIGHT_LENGTH", "0.5"), 64)
+	if err != nil {
+		return nil, err
+	}
+	qualityWeightRichness, err := strconv.ParseFloat(getEnvOrDefault("QUALITY_WEIGHT_RICHNESS", "0.3"), 64)
+	if err != nil {
+		return nil, err
+	}
+	qualityWeightSentence, err := strconv.ParseFloat(getEnvOrDefault("QUALITY_WEIGHT_SENTENCE", "0.2"), 64)
+	if err != nil {
+		return nil, err
+	}
+	httpDebug, err := strconv.ParseBool(getEnvOrDefault("HTTP_DEBUG", "false"))
+	if err != nil {
+		return nil, err
+	}
+	browserDebug, err := strconv.ParseBool(getEnvOrDefault("BROWSER_DEBUG", "false"))
+	if err != nil {
+		return nil, err
+	}
 
 	return &Config{
 		ProxyURL:               getEnv("PROXY_URL"),
@@ -45,9 +121,32 @@ func Load() (*Config, error) {
 		DomainWhiteListPath:    getEnv("DOMAIN_WHITELIST_PATH"),
 		TokenizerFilePath:      getEnv("TOKENIZER_FILE_PATH"),
 		BoltDBPath:             getEnv("BOLTDB_PATH"),
+		DeadLetterPath:         os.Getenv("DEAD_LETTER_PATH"),
+		QdrantDistance:         getEnvOrDefault("QDRANT_DISTANCE", "cosine"),
+		QdrantContentMode:      getEnvOrDefault("QDRANT_CONTENT_MODE", "full"),
+		ScreenshotDir:          getEnvOrDefault("SCREENSHOT_DIR", "screenshots"),
+		SearchBackend:          getEnvOrDefault("SEARCH_BACKEND", "auto"),
+		ChromeExecPath:         os.Getenv("CHROME_EXEC_PATH"),
+		ChromeRemoteURL:        os.Getenv("CHROME_REMOTE_URL"),
 		MaxEmbedModelTokenSize: tokenSize,
+		MinChunkTokens:         minChunkTokens,
 		QdrantPort:             qdrantPort,
+		QdrantVectorSize:       qdrantVectorSize,
+		QdrantMaxContentLen:    qdrantMaxContentLen,
 		AppPort:                appPort,
+		MaxLinksPerPage:        maxLinksPerPage,
+		MatchRegisteredDomain:  matchRegisteredDomain,
+		DeadLetterIncludeBody:  deadLetterIncludeBody,
+		StripBoilerplate:       stripBoilerplate,
+		QualityMinScore:        qualityMinScore,
+		QualityWeightLength:    qualityWeightLength,
+		QualityWeightRichness:  qualityWeightRichness,
+		QualityWeightSentence:  qualityWeightSentence,
+		SearchIncludeHosts:     splitList(os.Getenv("SEARCH_INCLUDE_HOSTS")),
+		SearchExcludeHosts:     splitList(os.Getenv("SEARCH_EXCLUDE_HOSTS")),
+		IPCheckEndpoints:       splitList(os.Getenv("IP_CHECK_ENDPOINTS")),
+		HTTPDebug:              httpDebug,
+		BrowserDebug:           browserDebug,
 	}, nil
 }
 
@@ -59,6 +158,24 @@ func getEnv(key string) string {
 	return value
 }
 
+func getEnvOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// splitList parses a comma-separated env value, skipping empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 type DomainConfig struct {
 	Domains []string `yaml:"domains"`
 	Seeds   []string `yaml:"seeds"`
@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/chromedp/chromedp"
+)
+
+// BlockedError reports that a search engine answered with a CAPTCHA or a
+// block page instead of results, callers can rotate the IP or back off
+type BlockedError struct {
+	Engine string
+	URL    string
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("%s blocked the search at %s: %s", e.Engine, e.URL, e.Reason)
+}
+
+// BlockDetection lists the markers of CAPTCHA and block pages. Titles and
+// texts are matched case-insensitively, hosts match the host or its subdomains.
+// Result pages echo snippets that may contain any phrase, so text markers are
+// only checked when the engine's results container is absent
+type BlockDetection struct {
+	TitleMarkers   []string
+	TextMarkers    []string
+	Selectors      []string
+	ChallengeHosts []string
+}
+
+var DefaultBlockDetection = BlockDetection{
+	// result page titles echo the query, so markers are kept to full phrases
+	TitleMarkers: []string{"access denied", "just a moment...", "attention required! | cloudflare"},
+	TextMarkers: []string{
+		"unusual traffic",
+		"are you a robot",
+		"verify you are human",
+		"complete the captcha",
+	},
+	Selectors: []string{
+		`iframe[src*="recaptcha"]`,
+		`iframe[src*="hcaptcha"]`,
+		`iframe[src*="challenges.cloudflare.com"]`,
+		`.g-recaptcha`,
+		`.h-captcha`,
+		`form#challenge-form`,
+	},
+	ChallengeHosts: []string{"challenges.cloudflare.com", "captcha-delivery.com"},
+}
+
+// detectBlock inspects the current tab and returns a *BlockedError when it
+// shows a block page
+func (b *Browser) detectBlock(ctx context.Context, engine SearchEngine, pageURL, title string) error {
+	selectors, err := json.Marshal(b.BlockDetection.Selectors)
+	if err != nil {
+		return err
+	}
+	resultSelector, err := json.Marshal(engine.ResultSelector)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`
+		(function(selectors, resultSelector) {
+			const matched = selectors.find(s => document.querySelector(s) !== null) || "";
+			const hasResults = resultSelector !== "" && document.querySelector(resultSelector) !== null;
+			const text = document.body ? document.body.innerText.slice(0, 20000) : "";
+			return {matched: matched, hasResults: hasResults, text: text};
+		})(%s, %s);
+	`, selectors, resultSelector)
+
+	var page struct {
+		Matched    string `json:"matched"`
+		HasResults bool   `json:"hasResults"`
+		Text       string `json:"text"`
+	}
+	if err := chromedp.Run(ctx, chromedp.Evaluate(script, &page)); err != nil {
+		return fmt.Errorf("failed to inspect page for blocks: %w", err)
+	}
+
+	if reason := b.BlockDetection.match(pageURL, title, page.Text, page.Matched, page.HasResults); reason != "" {
+		return &BlockedError{Engine: engine.Name, URL: pageURL, Reason: reason}
+	}
+	return nil
+}
+
+// match returns why the page looks like a block page, or "" when it does not.
+// hasResults reports whether the results container is on the page
+func (d BlockDetection) match(pageURL, title, text, matchedSelector string, hasResults bool) string {
+	if u, err := url.Parse(pageURL); err == nil {
+		host := strings.ToLower(u.Hostname())
+		for _, challenge := range d.ChallengeHosts {
+			if host == challenge || strings.HasSuffix(host, "."+challenge) {
+				return "redirected to challenge host " + host
+			}
+		}
+	}
+	if matchedSelector != "" {
+		return "captcha element " + matchedSelector
+	}
+
+	title = strings.ToLower(title)
+	for _, marker := range d.TitleMarkers {
+		if strings.Contains(title, marker) {
+			return "title contains " + marker
+		}
+	}
+	if hasResults {
+		return ""
+	}
+	text = strings.ToLower(text)
+	for _, marker := range d.TextMarkers {
+		if strings.Contains(text, marker) {
+			return "page text contains " + marker
+		}
+	}
+	return ""
+}
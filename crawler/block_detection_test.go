@@ -0,0 +1,130 @@
+package crawler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/chromedp/chromedp"
+)
+
+func TestBlockDetectionMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		pageURL    string
+		title      string
+		text       string
+		selector   string
+		hasResults bool
+		want       string // substring of the reason, "" when not blocked
+	}{
+		{
+			name:       "results page",
+			pageURL:    "https://duckduckgo.com/?q=golang",
+			title:      "golang at DuckDuckGo",
+			text:       "The Go Programming Language",
+			hasResults: true,
+		},
+		{
+			name:       "results echoing a marker in a snippet",
+			pageURL:    "https://duckduckgo.com/?q=bot+detection",
+			title:      "bot detection at DuckDuckGo",
+			text:       "Sites show a page asking are you a robot when they see unusual traffic",
+			hasResults: true,
+		},
+		{
+			name:    "text marker without results",
+			pageURL: "https://www.google.com/sorry/index",
+			title:   "Google",
+			text:    "Our systems have detected Unusual Traffic from your computer network",
+			want:    "unusual traffic",
+		},
+		{
+			name:    "challenge host",
+			pageURL: "https://geo.captcha-delivery.com/captcha/",
+			want:    "challenge host geo.captcha-delivery.com",
+		},
+		{
+			name:       "captcha element beats results",
+			pageURL:    "https://www.bing.com/search?q=golang",
+			selector:   ".g-recaptcha",
+			hasResults: true,
+			want:       "captcha element .g-recaptcha",
+		},
+		{
+			name:       "title marker",
+			pageURL:    "https://example.com/search",
+			title:      "Just a moment...",
+			hasResults: true,
+			want:       "title contains just a moment...",
+		},
+		{
+			name:    "query echoed in title is not a marker",
+			pageURL: "https://duckduckgo.com/?q=access",
+			title:   "access at DuckDuckGo",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DefaultBlockDetection.match(tt.pageURL, tt.title, tt.text, tt.selector, tt.hasResults)
+			if tt.want == "" {
+				if got != "" {
+					t.Fatalf("match = %q, want no block", got)
+				}
+				return
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Fatalf("match = %q, want it to contain %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBlockedErrorUnwrapsWithErrorsAs(t *testing.T) {
+	err := error(&BlockedError{Engine: "google", URL: "https://www.google.com/sorry", Reason: "captcha"})
+	wrapped := errors.Join(errors.New("collect failed"), err)
+
+	var blocked *BlockedError
+	if !errors.As(wrapped, &blocked) || blocked.Engine != "google" {
+		t.Fatalf("errors.As did not find the BlockedError in %v", wrapped)
+	}
+}
+
+func TestDetectBlockInspectsTheTab(t *testing.T) {
+	b, ctx := newTestTab(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/captcha":
+			fmt.Fprint(w, `<html><body><div class="g-recaptcha"></div></body></html>`)
+		case "/traffic":
+			fmt.Fprint(w, `<html><body>We detected unusual traffic from your network</body></html>`)
+		default:
+			fmt.Fprint(w, `<html><body><div id="results"><a href="https://go.dev/">unusual traffic</a></div></body></html>`)
+		}
+	}))
+	defer srv.Close()
+	engine := SearchEngine{Name: "test", ResultSelector: "#results"}
+
+	tests := map[string]string{
+		"/captcha": "captcha element .g-recaptcha",
+		"/traffic": "page text contains unusual traffic",
+		"/results": "",
+	}
+	for path, want := range tests {
+		if err := chromedp.Run(ctx, chromedp.Navigate(srv.URL+path)); err != nil {
+			t.Fatalf("navigate %s: %v", path, err)
+		}
+		err := b.detectBlock(ctx, engine, srv.URL+path, "")
+		var blocked *BlockedError
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%s: detectBlock = %v, want no block", path, err)
+		case want != "" && (!errors.As(err, &blocked) || blocked.Reason != want):
+			t.Errorf("%s: detectBlock = %v, want a block for %q", path, err, want)
+		}
+	}
+}
@@ -11,7 +11,10 @@ import (
 	bolt "go.etcd.io/bbolt"
 )
 
-var bucketName = []byte("colly")
+var (
+	bucketName         = []byte("colly")
+	frontierBucketName = []byte("frontier")
+)
 
 type BoltDBStorage struct {
 	DBPath string
@@ -19,8 +22,15 @@ type BoltDBStorage struct {
 	mu     sync.RWMutex
 }
 
-// Init initializes the BoltDB database
+// Init initializes the BoltDB database. Every crawl's collector shares the
+// storage and calls Init, so only the first call opens the file
 func (s *BoltDBStorage) Init() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.db != nil {
+		return nil
+	}
+
 	dbDir := filepath.Dir(s.DBPath)
 
 	if _, err := os.Stat(dbDir); os.IsNotExist(err) {
@@ -29,25 +39,22 @@ func (s *BoltDBStorage) Init() error {
 		}
 	}
 
-	dbExists := true
-	if _, err := os.Stat(s.DBPath); os.IsNotExist(err) {
-		dbExists = false
-	}
-
 	db, err := bolt.Open(s.DBPath, 0600, nil)
 	if err != nil {
 		return fmt.Errorf("failed to open BoltDB: %w", err)
 	}
 
-	if !dbExists {
-		err = db.Update(func(tx *bolt.Tx) error {
-			_, err := tx.CreateBucketIfNotExists(bucketName)
-			return err
-		})
-		if err != nil {
-			db.Close()
-			return fmt.Errorf("failed to create bucket: %w", err)
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketName, frontierBucketName} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return fmt.Errorf("failed to create bucket: %w", err)
 	}
 
 	s.db = db
@@ -118,12 +125,52 @@ func (s *BoltDBStorage) Clear() error {
 	defer s.mu.Unlock()
 
 	return s.db.Update(func(tx *bolt.Tx) error {
-		if err := tx.DeleteBucket(bucketName); err != nil {
-			return err
+		for _, name := range [][]byte{bucketName, frontierBucketName} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
 		}
-		_, err := tx.CreateBucket(bucketName)
-		return err
+		return nil
+	})
+}
+
+// AddToFrontier stores a serialized request that has been scheduled but not
+// yet scraped, keyed by its URL
+func (s *BoltDBStorage) AddToFrontier(u string, request []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(frontierBucketName).Put([]byte(u), request)
+	})
+}
+
+// RemoveFromFrontier drops a URL once it has been scraped or has failed
+func (s *BoltDBStorage) RemoveFromFrontier(u string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(frontierBucketName).Delete([]byte(u))
+	})
+}
+
+// Frontier returns every serialized request still pending in the frontier
+func (s *BoltDBStorage) Frontier() ([][]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var requests [][]byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(frontierBucketName).ForEach(func(_, v []byte) error {
+			requests = append(requests, append([]byte(nil), v...))
+			return nil
+		})
 	})
+	return requests, err
 }
 
 // Close closes the BoltDB database
@@ -16,12 +16,36 @@ type ChunkOutput struct {
 	Vector []float32 `json:"vector"`
 }
 
+const (
+	ChunkMarkdown = "md"
+	ChunkSentence = "sen"
+)
+
+// ValidateChunkMethod rejects chunk types ChunkText does not support, so a
+// crawl fails at the request instead of on every page
+func ValidateChunkMethod(method string) error {
+	switch method {
+	case ChunkMarkdown, ChunkSentence:
+		return nil
+	default:
+		return fmt.Errorf("unsupported chunk type %q, expected %s or %s", method, ChunkMarkdown, ChunkSentence)
+	}
+}
+
 type ChunkingClient interface {
-	ChunkText(text string, chunkType string) ([]ChunkOutput, error)
+	ChunkText(ctx context.Context, text string, chunkType string) ([]ChunkOutput, error)
+}
+
+// encoder is the part of *tokenizers.Tokenizer the Chunker uses to count
+// tokens
+type encoder interface {
+	Encode(str string, addSpecialTokens bool) ([]uint32, []string)
 }
 
 type Chunker struct {
-	tokenizer       *tokenizers.Tokenizer
+	tokenizer       encoder
+	markdown        textsplitter.TextSplitter
+	sentence        textsplitter.TextSplitter
 	maxTokens       int
 	minTokens       int
 	embeddingClient embedding.Client
@@ -29,39 +53,59 @@ type Chunker struct {
 	logger          *zap.Logger
 }
 
-func NewChunker(maxTokens int, embed embedding.Client, logger *zap.Logger,
+func NewChunker(maxTokens int, minTokens int, embed embedding.Client, logger *zap.Logger,
 	tokenizerFilePath string) (*Chunker, error) {
+	if minTokens < 0 || minTokens > maxTokens {
+		return nil, fmt.Errorf("min tokens must be between 0 and max tokens %d, got %d", maxTokens, minTokens)
+	}
 	tokenizer, err := tokenizers.FromFile(tokenizerFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load tokenizer from pretrained or local files: %w", err)
 	}
 	return &Chunker{
-		tokenizer:       tokenizer,
+		tokenizer: tokenizer,
+		markdown: textsplitter.NewMarkdownTextSplitter(
+			textsplitter.WithHeadingHierarchy(true),
+			textsplitter.WithChunkOverlap(50),
+		),
+		sentence: textsplitter.NewRecursiveCharacter(
+			textsplitter.WithSeparators([]string{"\n\n", "\n", ".", "!", "?", " ", ""}),
+			textsplitter.WithKeepSeparator(true),
+			textsplitter.WithChunkOverlap(50),
+		),
 		maxTokens:       maxTokens,
 		embeddingClient: embed,
 		maxBatchSize:    32,
 		logger:          logger,
-		minTokens:       75,
+		minTokens:       minTokens,
 	}, nil
 }
 
-func (sc *Chunker) ChunkText(text string, chunkType string) ([]ChunkOutput, error) {
-	var chunks []string
-	var err error
+// ChunkText splits text and embeds the chunks, cancelling ctx stops the
+// remaining embedding requests
+func (sc *Chunker) ChunkText(ctx context.Context, text string, chunkType string) ([]ChunkOutput, error) {
+	if strings.TrimSpace(text) == "" {
+		return []ChunkOutput{}, nil
+	}
 
+	var splitter textsplitter.TextSplitter
 	switch chunkType {
-	case "md":
-		chunks, err = sc.chunkMarkdown(text)
-	case "sen":
-		chunks, err = sc.chunkSentence(text)
+	case ChunkMarkdown:
+		splitter = sc.markdown
+	case ChunkSentence:
+		splitter = sc.sentence
 	default:
 		return nil, fmt.Errorf("unsupported chunk type: %s", chunkType)
 	}
 
+	split, err := splitter.SplitText(text)
 	if err != nil {
 		return nil, fmt.Errorf("failed to chunk text: %w", err)
 	}
 
+	// doChunk drops the chunks below minTokens, so a text too short to make
+	// one never reaches the embedding service
+	chunks := sc.doChunk(split)
 	if len(chunks) == 0 {
 		return []ChunkOutput{}, nil
 	}
@@ -75,7 +119,10 @@ func (sc *Chunker) ChunkText(text string, chunkType string) ([]ChunkOutput, erro
 		}
 
 		batch := chunks[i:end]
-		embeddings, err := sc.embeddingClient.GetEmbeddings(context.Background(), batch)
+		embeddings, err := sc.embeddingClient.GetEmbeddings(ctx, batch)
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		if err != nil {
 			sc.logger.Error("failed to get embeddings for batch",
 				zap.Int("start", i),
@@ -83,6 +130,13 @@ func (sc *Chunker) ChunkText(text string, chunkType string) ([]ChunkOutput, erro
 				zap.Error(err))
 			continue
 		}
+		if len(embeddings) != len(batch) {
+			sc.logger.Error("embedding count does not match batch size",
+				zap.Int("start", i),
+				zap.Int("end", end),
+				zap.Int("embeddings", len(embeddings)))
+			continue
+		}
 
 		for j, chunk := range batch {
 			results = append(results, ChunkOutput{
@@ -95,35 +149,7 @@ func (sc *Chunker) ChunkText(text string, chunkType string) ([]ChunkOutput, erro
 	return results, nil
 }
 
-func (sc *Chunker) chunkMarkdown(text string) ([]string, error) {
-	splitter := textsplitter.NewMarkdownTextSplitter(
-		textsplitter.WithHeadingHierarchy(true),
-		textsplitter.WithChunkOverlap(50),
-	)
-
-	c, err := splitter.SplitText(text)
-	if err != nil {
-		return nil, fmt.Errorf("failed to split markdown: %w", err)
-	}
-	return sc.doChunk(c)
-}
-
-func (sc *Chunker) chunkSentence(text string) ([]string, error) {
-	splitter := textsplitter.NewRecursiveCharacter(
-		textsplitter.WithSeparators([]string{"\n\n", "\n", ".", "!", "?", " ", ""}),
-		textsplitter.WithKeepSeparator(true),
-		textsplitter.WithChunkOverlap(50),
-	)
-
-	c, err := splitter.SplitText(text)
-	if err != nil {
-		return nil, fmt.Errorf("failed to split text: %w", err)
-	}
-
-	return sc.doChunk(c)
-}
-
-func (sc *Chunker) doChunk(chunks []string) ([]string, error) {
+func (sc *Chunker) doChunk(chunks []string) []string {
 	var validChunks []string
 	for _, chunk := range chunks {
 		trimmed := strings.TrimSpace(chunk)
@@ -135,7 +161,7 @@ func (sc *Chunker) doChunk(chunks []string) ([]string, error) {
 		tokenCount := len(ids)
 		sc.logger.Info("token_count", zap.Int("count", tokenCount))
 
-		if tokenCount < 75 {
+		if tokenCount < sc.minTokens {
 			continue
 		}
 		if tokenCount <= sc.maxTokens {
@@ -145,5 +171,5 @@ func (sc *Chunker) doChunk(chunks []string) ([]string, error) {
 		}
 	}
 
-	return validChunks, nil
+	return validChunks
 }
@@ -0,0 +1,153 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+// wordEncoder counts every word as one token
+type wordEncoder struct{}
+
+func (wordEncoder) Encode(str string, _ bool) ([]uint32, []string) {
+	words := strings.Fields(str)
+	return make([]uint32, len(words)), words
+}
+
+// paragraphSplitter splits on blank lines, standing in for the langchaingo
+// splitters
+type paragraphSplitter struct{}
+
+func (paragraphSplitter) SplitText(text string) ([]string, error) {
+	return strings.Split(text, "\n\n"), nil
+}
+
+// embedderFunc adapts a function to embedding.Client
+type embedderFunc func(ctx context.Context, texts []string) ([][]float32, error)
+
+func (f embedderFunc) GetEmbeddings(ctx context.Context, texts []string) ([][]float32, error) {
+	return f(ctx, texts)
+}
+
+// countingEmbedder returns a one-dimensional vector per text and counts calls
+type countingEmbedder struct {
+	calls int
+}
+
+func (e *countingEmbedder) GetEmbeddings(_ context.Context, texts []string) ([][]float32, error) {
+	e.calls++
+	vectors := make([][]float32, len(texts))
+	for i := range vectors {
+		vectors[i] = []float32{1}
+	}
+	return vectors, nil
+}
+
+func newTestChunker(maxTokens, minTokens int) (*Chunker, *countingEmbedder) {
+	embed := &countingEmbedder{}
+	return &Chunker{
+		tokenizer:       wordEncoder{},
+		markdown:        paragraphSplitter{},
+		sentence:        paragraphSplitter{},
+		maxTokens:       maxTokens,
+		minTokens:       minTokens,
+		embeddingClient: embed,
+		maxBatchSize:    32,
+		logger:          zap.NewNop(),
+	}, embed
+}
+
+func TestChunkTextSkipsTextBelowMinTokens(t *testing.T) {
+	sc, embed := newTestChunker(100, 5)
+	for _, text := range []string{"", "  \n ", "only four words here", "one two\n\nthree four"} {
+		chunks, err := sc.ChunkText(context.Background(), text, ChunkMarkdown)
+		if err != nil || len(chunks) != 0 {
+			t.Errorf("ChunkText(%q) = %v, %v, want no chunks", text, chunks, err)
+		}
+	}
+	if embed.calls != 0 {
+		t.Fatalf("embedding called %d times for short text", embed.calls)
+	}
+
+	chunks, err := sc.ChunkText(context.Background(), "five words are enough here\n\ntoo short", ChunkSentence)
+	if err != nil {
+		t.Fatalf("ChunkText: %v", err)
+	}
+	if embed.calls != 1 {
+		t.Fatalf("embedding called %d times for text at the minimum, want 1", embed.calls)
+	}
+	if len(chunks) != 1 || chunks[0].Text != "five words are enough here" {
+		t.Fatalf("ChunkText = %+v, want only the chunk at the minimum", chunks)
+	}
+
+	if _, err := sc.ChunkText(context.Background(), "five words are enough here", "words"); err == nil {
+		t.Fatal("ChunkText accepted an unsupported chunk type")
+	}
+}
+
+func TestChunkTextDropsBatchWithMissingEmbeddings(t *testing.T) {
+	text := "first chunk\n\nsecond chunk\n\nthird chunk"
+	for _, returned := range []int{0, 2} {
+		sc, _ := newTestChunker(100, 1)
+		sc.embeddingClient = embedderFunc(func(_ context.Context, texts []string) ([][]float32, error) {
+			vectors := make([][]float32, returned)
+			for i := range vectors {
+				vectors[i] = []float32{1}
+			}
+			return vectors, nil
+		})
+
+		chunks, err := sc.ChunkText(context.Background(), text, ChunkMarkdown)
+		if err != nil || len(chunks) != 0 {
+			t.Errorf("%d of 3 embeddings returned: ChunkText = %+v, %v, want the batch dropped", returned, chunks, err)
+		}
+	}
+}
+
+func TestChunkTextStopsBatchesWhenCancelled(t *testing.T) {
+	sc, _ := newTestChunker(100, 1)
+	sc.maxBatchSize = 2
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	sc.embeddingClient = embedderFunc(func(_ context.Context, texts []string) ([][]float32, error) {
+		calls++
+		cancel()
+		vectors := make([][]float32, len(texts))
+		for i := range vectors {
+			vectors[i] = []float32{1}
+		}
+		return vectors, nil
+	})
+
+	text := "one\n\ntwo\n\nthree\n\nfour\n\nfive"
+	chunks, err := sc.ChunkText(ctx, text, ChunkMarkdown)
+	if !errors.Is(err, context.Canceled) || chunks != nil {
+		t.Fatalf("ChunkText = %+v, %v, want no chunks and %v", chunks, err, context.Canceled)
+	}
+	if calls != 1 {
+		t.Fatalf("%d embedding batches sent, want none after the cancel", calls)
+	}
+}
+
+func TestDoChunkFiltersByTokenCount(t *testing.T) {
+	sc, _ := newTestChunker(4, 2)
+	chunks := sc.doChunk([]string{"one", " two words ", "", "exactly four words here", "five words is too many"})
+	if fmt.Sprint(chunks) != "[two words exactly four words here]" {
+		t.Fatalf("doChunk = %q, want the trimmed chunks within the token range", chunks)
+	}
+}
+
+func TestNewChunkerRejectsInvalidMinTokens(t *testing.T) {
+	for _, minTokens := range []int{-1, 513} {
+		if _, err := NewChunker(512, minTokens, &countingEmbedder{}, zap.NewNop(), "unused.json"); err == nil ||
+			!strings.Contains(err.Error(), "min tokens") {
+			t.Errorf("NewChunker with min tokens %d = %v, want it rejected", minTokens, err)
+		}
+	}
+}
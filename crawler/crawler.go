@@ -2,12 +2,18 @@ package crawler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"regexp"
+	"sync"
 	"time"
 
+	"axora/pkg/httpclient"
+	"axora/pkg/redact"
+
 	"github.com/gocolly/colly/v2"
-	"github.com/gocolly/colly/v2/storage"
 	"go.uber.org/zap"
 )
 
@@ -16,15 +22,117 @@ type DownloadableURL struct {
 	URL string
 }
 
-type CrawlVectorRepo interface {
+// VectorStore is the contract every vector database backend implements,
+// so the crawler does not depend on a specific store
+type VectorStore interface {
+	CreateCollection(ctx context.Context) error
 	InsertOne(ctx context.Context, doc *CrawlVectorDoc) error
+	InsertBatch(ctx context.Context, docs []*CrawlVectorDoc) error
+	DeleteByURL(ctx context.Context, url string) error
+	// ReplaceByURL stores docs as the chunks of url and drops the url's other
+	// chunks, so a failed re-index keeps the previous version
+	ReplaceByURL(ctx context.Context, url string, docs []*CrawlVectorDoc) error
+	Search(ctx context.Context, vector []float32, topK int, opts ...SearchOption) ([]SearchHit, error)
+	GetPoint(ctx context.Context, id string) (*StoredPoint, error)
 }
 
+// ErrPointNotFound is returned by VectorStore.GetPoint for unknown IDs
+var ErrPointNotFound = errors.New("point not found")
+
 type CrawlVectorDoc struct {
 	URL              string    `json:"url"`
+	Title            string    `json:"title"`
+	SiteName         string    `json:"sitename"`
 	Content          string    `json:"content"`
 	ContentEmbedding []float32 `json:"content_embedding"`
 	CrawledAt        time.Time `json:"crawledAt"`
+	QualityScore     float64   `json:"quality_score"`
+}
+
+// StoredPoint is a chunk as stored in the vector store, keyed by the ID
+// derived from its content
+type StoredPoint struct {
+	ID string `json:"id"`
+	CrawlVectorDoc
+}
+
+type SearchHit struct {
+	ID           string    `json:"id"`
+	URL          string    `json:"url"`
+	Title        string    `json:"title"`
+	SiteName     string    `json:"sitename"`
+	Content      string    `json:"content"`
+	CrawledAt    time.Time `json:"crawledAt"`
+	QualityScore float64   `json:"quality_score"`
+	Score        float32   `json:"score"`
+}
+
+type SearchOptions struct {
+	ScoreThreshold *float32
+	Hosts          []string
+	ExcludeIDs     []string
+}
+
+type SearchOption func(*SearchOptions)
+
+// WithScoreThreshold drops hits scoring worse than threshold. What worse means
+// depends on the collection metric: below threshold for cosine and dot, above
+// it for euclid and manhattan where the score is a distance
+func WithScoreThreshold(threshold float32) SearchOption {
+	return func(o *SearchOptions) {
+		o.ScoreThreshold = &threshold
+	}
+}
+
+// WithHosts restricts hits to chunks crawled from the given hosts,
+// an empty list searches all hosts
+func WithHosts(hosts ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.Hosts = hosts
+	}
+}
+
+// WithExcludeIDs drops the points with the given IDs from the hits
+func WithExcludeIDs(ids ...string) SearchOption {
+	return func(o *SearchOptions) {
+		o.ExcludeIDs = ids
+	}
+}
+
+// CrawlConfig holds the settings of a single crawl job
+type CrawlConfig struct {
+	ChunkMethod string
+	Topic       string
+	// SameDomainOnly only follows links within the registered domain of the
+	// seed they descend from, on top of the global domain whitelist
+	SameDomainOnly bool
+	// QualityWeights overrides the configured quality weights for this crawl
+	QualityWeights *QualityWeights
+	// ProxyURL overrides the default proxy for this crawl, only this crawl's
+	// requests go through it
+	ProxyURL string
+}
+
+// ValidateProxyURL checks that raw is an absolute http, https or socks5 URL
+func ValidateProxyURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return fmt.Errorf("unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("proxy url %q has no host", raw)
+	}
+	return nil
+}
+
+// logURL is the zap field for URLs, with download keys and credentials masked
+func logURL(u string) zap.Field {
+	return zap.String("url", redact.URL(u))
 }
 
 type ContextKey string
@@ -35,28 +143,121 @@ const (
 	LinkID       ContextKey = "link_id"
 )
 
+// seedDomainKey is the colly request context key holding the registered
+// domain of the seed a request descends from
+const seedDomainKey = "seed_domain"
+
 type Crawler struct {
+	logger          *zap.Logger
+	httpConfig      httpclient.Config // ProxyURL is the default proxy of every job
+	crawlVector     VectorStore
+	chunkingClient  ChunkingClient
+	storage         *BoltDBStorage
+	domains         []string
+	limit           colly.LimitRule
+	quality         QualityConfig
+	maxLinksPerPage int             // 0 means unlimited
+	deadLetters     *DeadLetterSink // nil disables dead-lettering
+	// allowedRegisteredDomains is set when the whitelist is matched on
+	// registered domain instead of colly's exact host match
+	allowedRegisteredDomains map[string]struct{}
+	resumeOnce               sync.Once
+
+	mu         sync.Mutex
+	lastDepths map[int]int // pages per depth of the last finished crawl
+}
+
+// crawlJob is the state of a single Crawl call. Each job has its own
+// collector with the callbacks registered once, so concurrent crawls neither
+// share settings nor run each other's callbacks
+type crawlJob struct {
+	*Crawler
 	collector      *colly.Collector
-	logger         *zap.Logger
-	httpClient     http.Client
-	proxyUrl       string
-	crawlVector    CrawlVectorRepo
-	chunkingClient ChunkingClient
-	storage        storage.Storage
+	transport      *http.Transport
+	ctx            context.Context
 	chunkMethod    string
 	topic          string
+	sameDomainOnly bool
+	qualityWeights QualityWeights
+	depthStats     *depthStats
+	referers       sync.Map // scheduled URL -> page that linked it
+	// frontierKeys maps a request ID to the URL its frontier entry is stored
+	// under, the request URL changes when a redirect is followed
+	frontierKeys sync.Map
 }
 
 func NewCrawler(
-	proxyUrl string,
-	httpClient *http.Client,
-	httpTransport *http.Transport,
+	httpConfig httpclient.Config,
 	logger *zap.Logger,
-	crawlVector CrawlVectorRepo,
+	crawlVector VectorStore,
 	chunkingClient ChunkingClient,
 	domains []string,
 	boltDBPath string,
+	maxLinksPerPage int,
+	matchRegisteredDomain bool,
+	deadLetters *DeadLetterSink,
+	quality QualityConfig,
 ) (*Crawler, error) {
+	quality, err := quality.validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var allowedRegisteredDomains map[string]struct{}
+	if matchRegisteredDomain {
+		allowedRegisteredDomains = make(map[string]struct{}, len(domains))
+		for _, d := range domains {
+			allowedRegisteredDomains[registeredDomain(d)] = struct{}{}
+		}
+	}
+
+	storage := &BoltDBStorage{
+		DBPath: boltDBPath,
+	}
+	if err := storage.Init(); err != nil {
+		return nil, err
+	}
+
+	worker := &Crawler{
+		logger:          logger,
+		httpConfig:      httpConfig,
+		crawlVector:     crawlVector,
+		chunkingClient:  chunkingClient,
+		storage:         storage,
+		domains:         domains,
+		maxLinksPerPage: maxLinksPerPage,
+		deadLetters:     deadLetters,
+		quality:         quality,
+		limit: colly.LimitRule{
+			DomainGlob:  "*",
+			Parallelism: 3,
+			Delay:       5 * time.Second,
+			RandomDelay: 3 * time.Second,
+		},
+
+		allowedRegisteredDomains: allowedRegisteredDomains,
+	}
+
+	return worker, nil
+}
+
+// newCollector builds the collector of one crawl with its own transport
+// routed through proxy, cancelling ctx aborts its requests in flight. The
+// storage is shared, so the visited set and the frontier span every crawl
+func (w *Crawler) newCollector(ctx context.Context, proxy string) (*colly.Collector, *http.Transport, error) {
+	httpConfig := w.httpConfig
+	httpConfig.ProxyURL = proxy
+	client, transport, err := httpclient.New(httpConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	domainFilter := colly.AllowedDomains(w.domains...)
+	if w.allowedRegisteredDomains != nil {
+		// subdomains are checked in OnRequest, colly only knows exact hosts
+		domainFilter = colly.AllowedDomains()
+	}
+
 	c := colly.NewCollector(
 		colly.UserAgent("Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 "+
 			"(KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"),
@@ -64,7 +265,8 @@ func NewCrawler(
 		colly.Async(true),
 		colly.TraceHTTP(),
 		colly.ParseHTTPErrorResponse(),
-		colly.AllowedDomains(domains...),
+		colly.StdlibContext(ctx),
+		domainFilter,
 		colly.URLFilters(
 			regexp.MustCompile(`^https://.*$`),
 			regexp.MustCompile(`^https://libgen\.li/index\.php\?req=[^&]+$`),
@@ -75,58 +277,161 @@ func NewCrawler(
 		),
 		// colly.Debugger(&debug.LogDebugger{}),
 	)
-	storage := &BoltDBStorage{
-		DBPath: boltDBPath,
+	if err := c.SetStorage(w.storage); err != nil {
+		return nil, nil, err
 	}
-	if err := c.SetStorage(storage); err != nil {
-		return nil, err
+	// only the transport is replaced, colly's client keeps its cookie jar
+	// and redirect checks
+	c.WithTransport(client.Transport)
+	c.SetRequestTimeout(httpConfig.Timeout)
+	limit := w.limit
+	if err := c.Limit(&limit); err != nil {
+		return nil, nil, err
 	}
-	c.WithTransport(httpTransport)
-	c.SetClient(httpClient)
-	c.SetRequestTimeout(5 * time.Minute)
-	err := c.Limit(&colly.LimitRule{
-		DomainGlob:  "*",
-		Parallelism: 3,
-		Delay:       5 * time.Second,
-		RandomDelay: 3 * time.Second,
-	})
+	c.IgnoreRobotsTxt = true
+	return c, transport, nil
+}
+
+// newJob prepares a crawl with cfg, registering the callbacks on a fresh
+// collector
+func (w *Crawler) newJob(ctx context.Context, cfg CrawlConfig) (*crawlJob, error) {
+	collector, transport, err := w.newCollector(ctx, w.jobProxy(cfg.ProxyURL))
 	if err != nil {
 		return nil, err
 	}
-	c.IgnoreRobotsTxt = true
-
-	worker := &Crawler{
-		collector:      c,
-		logger:         logger,
-		httpClient:     *httpClient,
-		proxyUrl:       proxyUrl,
-		crawlVector:    crawlVector,
-		chunkingClient: chunkingClient,
-		storage:        storage,
+	job := &crawlJob{
+		Crawler:        w,
+		collector:      collector,
+		transport:      transport,
+		ctx:            ctx,
+		chunkMethod:    cfg.ChunkMethod,
+		topic:          cfg.Topic,
+		sameDomainOnly: cfg.SameDomainOnly,
+		qualityWeights: w.quality.Weights,
+		depthStats:     newDepthStats(),
+	}
+	if cfg.QualityWeights != nil {
+		weights, err := cfg.QualityWeights.Normalize()
+		if err != nil {
+			w.logger.Warn("invalid quality weights, using configured ones", zap.Error(err))
+		} else {
+			job.qualityWeights = weights
+		}
 	}
 
-	return worker, nil
+	collector.OnHTML("html", job.OnHTML())
+	collector.OnRequest(job.OnRequest())
+	// collector.OnHTML("body", w.OnHTMLDOMLog())
+	collector.OnError(job.OnError())
+	collector.OnResponse(job.OnResponse())
+	collector.OnScraped(job.OnScraped())
+	return job, nil
 }
 
-func (w *Crawler) Crawl(urls chan string, chunkMethod string, topic string) error {
-	w.collector.OnHTML("a[href]", w.OnHTML())
-	w.collector.OnRequest(w.OnRequest())
-	// w.collector.OnHTML("body", w.OnHTMLDOMLog(ctx))
-	w.collector.OnError(w.OnError(w.collector))
-	w.collector.OnResponse(w.OnResponse())
-	w.chunkMethod = chunkMethod
-	w.topic = topic
+// Crawl visits urls and everything reachable from them. Cancelling ctx aborts
+// pending requests and stops in-flight chunking and indexing
+func (w *Crawler) Crawl(ctx context.Context, urls chan string, cfg CrawlConfig) error {
+	job, err := w.newJob(ctx, cfg)
+	if err != nil {
+		// keep draining urls so the sender is not blocked
+		for range urls {
+		}
+		return fmt.Errorf("failed to start crawl: %w", err)
+	}
+
+	// the frontier holds requests of an interrupted process, resuming it
+	// again would refetch what concurrent crawls are working on
+	w.resumeOnce.Do(job.resumeFrontier)
 
 	for url := range urls {
-		if err := w.collector.Visit(url); err != nil {
+		// keep draining urls so the sender is not blocked after cancellation
+		if ctx.Err() != nil {
+			continue
+		}
+		if err := job.visitSeed(url); err != nil {
 			w.logger.Error("Failed to visit URL",
-				zap.String("url", url),
+				logURL(url),
 				zap.Error(err))
 			continue
 		}
 	}
-	w.collector.Wait()
-	w.logger.Info("Crawl session completed")
+	job.collector.Wait()
+	// the transport dies with the job, its pooled connections go with it
+	job.transport.CloseIdleConnections()
 
-	return nil
+	histogram := job.depthStats.snapshot()
+	w.mu.Lock()
+	w.lastDepths = histogram
+	w.mu.Unlock()
+	pages := 0
+	for _, count := range histogram {
+		pages += count
+	}
+	w.logger.Info("Crawl session completed",
+		zap.Int("pages", pages),
+		zap.Any("pages_by_depth", histogram))
+
+	return ctx.Err()
+}
+
+// visitSeed schedules a seed, recording its registered domain in the request
+// context that every page of its link tree shares
+func (w *crawlJob) visitSeed(seed string) error {
+	parsed, err := url.Parse(seed)
+	if err != nil {
+		return err
+	}
+	ctx := colly.NewContext()
+	ctx.Put(seedDomainKey, registeredDomain(parsed.Hostname()))
+	return w.collector.Request(http.MethodGet, seed, nil, ctx, nil)
+}
+
+// jobProxy returns override when it is a valid proxy URL, the default proxy
+// otherwise
+func (w *Crawler) jobProxy(override string) string {
+	if override == "" {
+		return w.httpConfig.ProxyURL
+	}
+	if err := ValidateProxyURL(override); err != nil {
+		w.logger.Warn("invalid proxy override, using default", zap.Error(err))
+		return w.httpConfig.ProxyURL
+	}
+	return override
+}
+
+// resumeFrontier re-schedules requests left in the persistent frontier by an
+// interrupted crawl, running them with this job's settings. They were already
+// marked visited before the interruption, so Retry is used to bypass colly's
+// revisit check
+func (w *crawlJob) resumeFrontier() {
+	requests, err := w.storage.Frontier()
+	if err != nil {
+		w.logger.Error("Failed to read crawl frontier", zap.Error(err))
+		return
+	}
+	if len(requests) == 0 {
+		return
+	}
+
+	resumed := 0
+	for _, data := range requests {
+		req, err := w.collector.UnmarshalRequest(data)
+		if err != nil {
+			w.logger.Error("Failed to decode frontier request", zap.Error(err))
+			continue
+		}
+		if err := req.Retry(); err != nil {
+			w.logger.Warn("Failed to resume frontier request",
+				logURL(req.URL.String()),
+				zap.Error(err))
+			if err := w.storage.RemoveFromFrontier(req.URL.String()); err != nil {
+				w.logger.Error("Failed to remove frontier request", zap.Error(err))
+			}
+			continue
+		}
+		resumed++
+	}
+	w.logger.Info("Resumed crawl frontier",
+		zap.Int("pending", len(requests)),
+		zap.Int("resumed", resumed))
 }
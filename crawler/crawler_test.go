@@ -0,0 +1,509 @@
+package crawler
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"axora/pkg/httpclient"
+
+	"github.com/gocolly/colly/v2"
+	"go.uber.org/zap"
+)
+
+var _ VectorStore = (*fakeStore)(nil)
+
+// fakeStore is an in-memory VectorStore recording what the crawler indexed
+type fakeStore struct {
+	mu     sync.Mutex
+	chunks map[string][]*CrawlVectorDoc // url -> chunks
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{chunks: make(map[string][]*CrawlVectorDoc)}
+}
+
+func (s *fakeStore) CreateCollection(context.Context) error { return nil }
+
+func (s *fakeStore) InsertOne(ctx context.Context, doc *CrawlVectorDoc) error {
+	return s.InsertBatch(ctx, []*CrawlVectorDoc{doc})
+}
+
+func (s *fakeStore) InsertBatch(_ context.Context, docs []*CrawlVectorDoc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, doc := range docs {
+		s.chunks[doc.URL] = append(s.chunks[doc.URL], doc)
+	}
+	return nil
+}
+
+func (s *fakeStore) DeleteByURL(_ context.Context, url string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.chunks, url)
+	return nil
+}
+
+func (s *fakeStore) ReplaceByURL(_ context.Context, url string, docs []*CrawlVectorDoc) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks[url] = docs
+	return nil
+}
+
+func (s *fakeStore) Search(context.Context, []float32, int, ...SearchOption) ([]SearchHit, error) {
+	return nil, nil
+}
+
+func (s *fakeStore) GetPoint(context.Context, string) (*StoredPoint, error) {
+	return nil, ErrPointNotFound
+}
+
+// fakeChunker returns the whole text as a single chunk, or err when set
+type fakeChunker struct {
+	err error
+}
+
+func (f fakeChunker) ChunkText(_ context.Context, text string, _ string) ([]ChunkOutput, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return []ChunkOutput{{Text: text, Vector: []float32{1}}}, nil
+}
+
+// site serves HTML pages linking to each other and counts the hits per path
+type site struct {
+	*httptest.Server
+	mu       sync.Mutex
+	links    map[string][]string // path -> hrefs on the page
+	redirect map[string]string   // path -> location it redirects to
+	anchors  map[string]string   // href -> anchor text, "link" by default
+	hits     map[string]int
+	referers map[string]string
+}
+
+func newSite(t *testing.T, links map[string][]string) *site {
+	t.Helper()
+	s := &site{
+		links:    links,
+		redirect: make(map[string]string),
+		anchors:  make(map[string]string),
+		hits:     make(map[string]int),
+		referers: make(map[string]string),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *site) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.hits[r.URL.Path]++
+	s.referers[r.URL.Path] = r.Header.Get("Referer")
+	hrefs, ok := s.links[r.URL.Path]
+	location, redirect := s.redirect[r.URL.Path]
+	s.mu.Unlock()
+	if redirect {
+		http.Redirect(w, r, location, http.StatusMovedPermanently)
+		return
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var body strings.Builder
+	body.WriteString("<html><head><title>page</title></head><body>")
+	for _, href := range hrefs {
+		text := "link"
+		if anchor, ok := s.anchors[href]; ok {
+			text = anchor
+		}
+		fmt.Fprintf(&body, `<a href="%s">%s</a>`, href, text)
+	}
+	body.WriteString("</body></html>")
+	w.Header().Set("Content-Type", "text/html")
+	_, _ = w.Write([]byte(body.String()))
+}
+
+func (s *site) hitCount(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.hits[path]
+}
+
+// newTestCrawler builds a crawler trusting the test server's certificate,
+// without politeness delays and with a fresh storage
+func newTestCrawler(t *testing.T, srv *httptest.Server, domains ...string) *Crawler {
+	t.Helper()
+	return newTestCrawlerAt(t, srv, filepath.Join(t.TempDir(), "crawl.db"), domains...)
+}
+
+// newTestCrawlerAt is newTestCrawler with the storage at dbPath, so a crawler
+// can pick up what a previous one left behind
+func newTestCrawlerAt(t *testing.T, srv *httptest.Server, dbPath string, domains ...string) *Crawler {
+	t.Helper()
+	if len(domains) == 0 {
+		domains = []string{"127.0.0.1"}
+	}
+	httpConfig := httpclient.DefaultConfig()
+	httpConfig.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig
+	c, err := NewCrawler(httpConfig, zap.NewNop(),
+		newFakeStore(), fakeChunker{}, domains, dbPath,
+		0, false, nil, QualityConfig{MinScore: 50})
+	if err != nil {
+		t.Fatalf("NewCrawler: %v", err)
+	}
+	c.limit.Delay = 0
+	c.limit.RandomDelay = 0
+	t.Cleanup(func() { _ = c.storage.Close() })
+	return c
+}
+
+// seeds returns a closed channel holding urls
+func seeds(urls ...string) chan string {
+	ch := make(chan string, len(urls))
+	for _, u := range urls {
+		ch <- u
+	}
+	close(ch)
+	return ch
+}
+
+// crawl runs a crawl over urls. The topic matches no page, so pages are
+// fetched and their links followed without being extracted
+func crawl(t *testing.T, c *Crawler, cfg CrawlConfig, urls ...string) {
+	t.Helper()
+	if cfg.Topic == "" {
+		cfg.Topic = "zymurgy"
+	}
+	if err := c.Crawl(context.Background(), seeds(urls...), cfg); err != nil {
+		t.Fatalf("Crawl: %v", err)
+	}
+}
+
+func TestCrawlCountsPagesPerDepthOnEveryCrawl(t *testing.T) {
+	srv := newSite(t, map[string][]string{
+		"/first":    {"/first/a", "/first/b"},
+		"/first/a":  {"/first/c"},
+		"/first/b":  {"/first/a"},
+		"/first/c":  nil,
+		"/second":   {"/second/a"},
+		"/second/a": nil,
+	})
+	c := newTestCrawler(t, srv.Server)
+
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown}, srv.URL+"/first")
+	want := map[int]int{1: 1, 2: 2}
+	if got := c.DepthHistogram(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("first crawl depths = %v, want %v", got, want)
+	}
+	if n := srv.hitCount("/first/c"); n != 0 {
+		t.Fatalf("a depth 3 page was fetched %d times", n)
+	}
+
+	// a second crawl on the same crawler must not run the callbacks twice
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown}, srv.URL+"/second")
+	want = map[int]int{1: 1, 2: 1}
+	if got := c.DepthHistogram(); fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("second crawl depths = %v, want %v", got, want)
+	}
+	for _, path := range []string{"/first", "/first/a", "/first/b", "/second", "/second/a"} {
+		if n := srv.hitCount(path); n != 1 {
+			t.Errorf("%s fetched %d times, want once", path, n)
+		}
+	}
+}
+
+// connectProxy tunnels CONNECT requests and records the targets it was asked
+// to reach. Targets listed in routes are dialed at the mapped address, which
+// lets tests serve arbitrary host names
+type connectProxy struct {
+	*httptest.Server
+	mu      sync.Mutex
+	targets map[string]int
+	routes  map[string]string
+}
+
+func newConnectProxy(t *testing.T) *connectProxy {
+	t.Helper()
+	p := &connectProxy{targets: make(map[string]int), routes: make(map[string]string)}
+	p.Server = httptest.NewServer(http.HandlerFunc(p.serve))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *connectProxy) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect {
+		http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	p.mu.Lock()
+	p.targets[r.Host]++
+	addr, ok := p.routes[r.Host]
+	p.mu.Unlock()
+	if !ok {
+		addr = r.Host
+	}
+
+	upstream, err := net.Dial("tcp", addr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		upstream.Close()
+		return
+	}
+	_, _ = conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\n"))
+	go func() {
+		_, _ = io.Copy(upstream, conn)
+		upstream.Close()
+	}()
+	_, _ = io.Copy(conn, upstream)
+	conn.Close()
+}
+
+func (p *connectProxy) tunnels() map[string]int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return maps.Clone(p.targets)
+}
+
+func TestCrawlUsesItsOwnProxy(t *testing.T) {
+	siteA := newSite(t, map[string][]string{"/": {"/a"}, "/a": nil})
+	siteB := newSite(t, map[string][]string{"/": {"/b"}, "/b": nil})
+	proxyA, proxyB := newConnectProxy(t), newConnectProxy(t)
+	c := newTestCrawler(t, siteA.Server)
+
+	// both crawls run at once, each must keep to its own proxy
+	var wg sync.WaitGroup
+	for _, job := range []struct {
+		site  *site
+		proxy *connectProxy
+	}{{siteA, proxyA}, {siteB, proxyB}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			cfg := CrawlConfig{ChunkMethod: ChunkMarkdown, Topic: "zymurgy", ProxyURL: job.proxy.URL}
+			if err := c.Crawl(context.Background(), seeds(job.site.URL+"/"), cfg); err != nil {
+				t.Errorf("Crawl through %s: %v", job.proxy.URL, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, tt := range []struct {
+		name  string
+		site  *site
+		proxy *connectProxy
+		page  string
+	}{{"A", siteA, proxyA, "/a"}, {"B", siteB, proxyB, "/b"}} {
+		if n := tt.site.hitCount(tt.page); n != 1 {
+			t.Errorf("site %s: %s fetched %d times, want once", tt.name, tt.page, n)
+		}
+		tunnels := tt.proxy.tunnels()
+		host := tt.site.Listener.Addr().String()
+		if len(tunnels) != 1 || tunnels[host] == 0 {
+			t.Errorf("proxy %s tunnelled to %v, want only %s", tt.name, tunnels, host)
+		}
+	}
+}
+
+func frontierLen(t *testing.T, c *Crawler) int {
+	t.Helper()
+	requests, err := c.storage.Frontier()
+	if err != nil {
+		t.Fatalf("Frontier: %v", err)
+	}
+	return len(requests)
+}
+
+func TestCrawlRemovesRedirectedRequestFromFrontier(t *testing.T) {
+	srv := newSite(t, map[string][]string{"/": {"/old"}, "/new": nil})
+	srv.redirect["/old"] = "/new"
+	c := newTestCrawler(t, srv.Server)
+
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown}, srv.URL+"/")
+	if srv.hitCount("/new") != 1 {
+		t.Fatalf("the redirect target was fetched %d times, want once", srv.hitCount("/new"))
+	}
+	if n := frontierLen(t, c); n != 0 {
+		t.Fatalf("%d requests left in the frontier after the crawl, want 0", n)
+	}
+}
+
+func TestCrawlResumesInterruptedFrontier(t *testing.T) {
+	srv := newSite(t, map[string][]string{"/": {"/pending"}, "/moved": nil})
+	srv.redirect["/pending"] = "/moved"
+	dbPath := filepath.Join(t.TempDir(), "crawl.db")
+
+	// the first process schedules /pending and dies before fetching it
+	first := newTestCrawlerAt(t, srv.Server, dbPath)
+	job, err := first.newJob(context.Background(), CrawlConfig{ChunkMethod: ChunkMarkdown, Topic: "zymurgy"})
+	if err != nil {
+		t.Fatalf("newJob: %v", err)
+	}
+	job.collector.OnRequest(func(r *colly.Request) { r.Abort() })
+	if err := job.collector.Visit(srv.URL + "/pending"); err != nil {
+		t.Fatalf("Visit: %v", err)
+	}
+	job.collector.Wait()
+	if n := frontierLen(t, first); n != 1 {
+		t.Fatalf("%d requests in the frontier of the interrupted crawl, want 1", n)
+	}
+	if err := first.storage.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	second := newTestCrawlerAt(t, srv.Server, dbPath)
+	crawl(t, second, CrawlConfig{ChunkMethod: ChunkMarkdown})
+	if srv.hitCount("/pending") != 1 || srv.hitCount("/moved") != 1 {
+		t.Fatalf("resumed request fetched /pending %d and /moved %d times, want once each",
+			srv.hitCount("/pending"), srv.hitCount("/moved"))
+	}
+	if n := frontierLen(t, second); n != 0 {
+		t.Fatalf("%d requests left in the frontier after resuming, want 0", n)
+	}
+}
+
+func TestSameDomainOnlyKeepsToTheSeedDomain(t *testing.T) {
+	com := newSite(t, map[string][]string{
+		"/":     {"/page", "https://example.org/linked"},
+		"/page": nil,
+		"/back": nil,
+	})
+	com.redirect["/moved"] = "https://example.org/"
+	org := newSite(t, map[string][]string{
+		"/":       {"/deeper", "https://example.com/back"},
+		"/linked": nil,
+		"/deeper": nil,
+	})
+	proxy := newConnectProxy(t)
+	proxy.routes["example.com:443"] = com.Listener.Addr().String()
+	proxy.routes["example.org:443"] = org.Listener.Addr().String()
+
+	c := newTestCrawler(t, com.Server, "example.com", "example.org")
+	c.httpConfig.ProxyURL = proxy.URL
+	// the test certificate does not cover example.org
+	c.httpConfig.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+
+	// the second seed redirects off its domain, the links of the page it
+	// lands on are still held to example.com
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown, SameDomainOnly: true},
+		"https://example.com/", "https://example.com/moved")
+
+	if n := com.hitCount("/page"); n != 1 {
+		t.Errorf("same-domain link fetched %d times, want once", n)
+	}
+	if n := org.hitCount("/linked"); n != 0 {
+		t.Errorf("cross-domain link fetched %d times, want skipped", n)
+	}
+	if n := org.hitCount("/"); n != 1 {
+		t.Fatalf("redirect target fetched %d times, want once", n)
+	}
+	if n := org.hitCount("/deeper"); n != 0 {
+		t.Errorf("link on the redirect target's domain fetched %d times, want skipped", n)
+	}
+	if n := com.hitCount("/back"); n != 1 {
+		t.Errorf("link back to the seed domain fetched %d times, want once", n)
+	}
+}
+
+func TestCrawlWithCancelledContextFetchesNothing(t *testing.T) {
+	srv := newSite(t, map[string][]string{"/": {"/a"}, "/a": nil})
+	c := newTestCrawler(t, srv.Server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := c.Crawl(ctx, seeds(srv.URL+"/"), CrawlConfig{ChunkMethod: ChunkMarkdown, Topic: "zymurgy"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Crawl = %v, want context.Canceled", err)
+	}
+	if n := srv.hitCount("/"); n != 0 {
+		t.Fatalf("a cancelled crawl fetched the seed %d times", n)
+	}
+}
+
+func TestCrawlCancelAbortsRequestInFlight(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	release := make(chan struct{})
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	c := newTestCrawler(t, srv)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	result := make(chan error, 1)
+	go func() {
+		result <- c.Crawl(ctx, seeds(srv.URL+"/slow"), CrawlConfig{ChunkMethod: ChunkMarkdown, Topic: "zymurgy"})
+	}()
+
+	<-arrived
+	cancel()
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Crawl = %v, want context.Canceled", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Crawl kept waiting for the response after cancellation")
+	}
+}
+
+func TestCrawlCapsLinksPerPageKeepingRelevantOnes(t *testing.T) {
+	srv := newSite(t, map[string][]string{
+		"/":      {"/one", "/two", "/three", "/brew"},
+		"/one":   nil,
+		"/two":   nil,
+		"/three": nil,
+		"/brew":  nil,
+	})
+	srv.anchors["/brew"] = "zymurgy basics"
+	c := newTestCrawler(t, srv.Server)
+	c.maxLinksPerPage = 2
+
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown}, srv.URL+"/")
+
+	// the topic link is found last but kept ahead of the others
+	for path, want := range map[string]int{"/brew": 1, "/one": 1, "/two": 0, "/three": 0} {
+		if n := srv.hitCount(path); n != want {
+			t.Errorf("%s fetched %d times, want %d", path, n, want)
+		}
+	}
+}
+
+func TestCrawlSendsLinkingPageAsReferer(t *testing.T) {
+	srv := newSite(t, map[string][]string{"/": {"/download?md5=abc"}, "/download": nil})
+	c := newTestCrawler(t, srv.Server)
+
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown}, srv.URL+"/")
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if got := srv.referers["/"]; got != "" {
+		t.Errorf("seed sent Referer %q, want none", got)
+	}
+	if got, want := srv.referers["/download"], srv.URL+"/"; got != want {
+		t.Errorf("linked page sent Referer %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,80 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly/v2"
+	"go.uber.org/zap"
+)
+
+type DeadLetter struct {
+	URL      string    `json:"url"`
+	Status   int       `json:"status"`
+	Reason   string    `json:"reason"`
+	Body     string    `json:"body,omitempty"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadLetterSink appends pages that could not be extracted to a JSONL file
+// for later analysis
+type DeadLetterSink struct {
+	mu          sync.Mutex
+	file        *os.File
+	includeBody bool
+}
+
+func NewDeadLetterSink(path string, includeBody bool) (*DeadLetterSink, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dead letter file: %w", err)
+	}
+	return &DeadLetterSink{file: f, includeBody: includeBody}, nil
+}
+
+func (s *DeadLetterSink) Write(record DeadLetter) error {
+	if !s.includeBody {
+		record.Body = ""
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *DeadLetterSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
+
+// deadLetter records a failed page, a no-op when the sink is disabled
+func (w *Crawler) deadLetter(r *colly.Response, reason string) {
+	if w.deadLetters == nil {
+		return
+	}
+	err := w.deadLetters.Write(DeadLetter{
+		URL:      r.Request.URL.String(),
+		Status:   r.StatusCode,
+		Reason:   reason,
+		Body:     string(r.Body),
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		w.logger.Error("failed to write dead letter",
+			logURL(r.Request.URL.String()),
+			zap.Error(err))
+	}
+}
@@ -0,0 +1,96 @@
+package crawler
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gocolly/colly/v2"
+	"go.uber.org/zap"
+)
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("open dead letters: %v", err)
+	}
+	defer f.Close()
+
+	var records []DeadLetter
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("line %q is not a dead letter: %v", scanner.Text(), err)
+		}
+		records = append(records, record)
+	}
+	return records
+}
+
+func failedResponse(t *testing.T, rawURL string, status int, body string) *colly.Response {
+	t.Helper()
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatalf("parse %q: %v", rawURL, err)
+	}
+	return &colly.Response{Request: &colly.Request{URL: u}, StatusCode: status, Body: []byte(body)}
+}
+
+func TestDeadLetterSinkAppendsRecords(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "dead.jsonl")
+	for i, reason := range []string{"extraction failed", "below quality threshold"} {
+		sink, err := NewDeadLetterSink(path, false)
+		if err != nil {
+			t.Fatalf("NewDeadLetterSink: %v", err)
+		}
+		c := &Crawler{logger: zap.NewNop(), deadLetters: sink}
+		c.deadLetter(failedResponse(t, "https://a.example/page", 200+i, "<html>secret</html>"), reason)
+		if err := sink.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+	}
+
+	records := readDeadLetters(t, path)
+	if len(records) != 2 {
+		t.Fatalf("%d records, want one per sink session", len(records))
+	}
+	first := records[0]
+	if first.URL != "https://a.example/page" || first.Status != 200 || first.Reason != "extraction failed" {
+		t.Errorf("first record = %+v", first)
+	}
+	if first.FailedAt.IsZero() {
+		t.Error("failure time was not recorded")
+	}
+	if records[1].Reason != "below quality threshold" {
+		t.Errorf("second record = %+v, the reopened sink did not append", records[1])
+	}
+	for _, record := range records {
+		if record.Body != "" {
+			t.Errorf("body %q stored although includeBody is off", record.Body)
+		}
+	}
+}
+
+func TestDeadLetterSinkIncludesBodyWhenEnabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	sink, err := NewDeadLetterSink(path, true)
+	if err != nil {
+		t.Fatalf("NewDeadLetterSink: %v", err)
+	}
+	c := &Crawler{logger: zap.NewNop(), deadLetters: sink}
+	c.deadLetter(failedResponse(t, "https://a.example/page", 200, "<p>kept</p>"), "parse failed")
+	sink.Close()
+
+	records := readDeadLetters(t, path)
+	if len(records) != 1 || records[0].Body != "<p>kept</p>" {
+		t.Fatalf("records = %+v, want the page body", records)
+	}
+
+	// without a sink dead-lettering is a no-op
+	(&Crawler{logger: zap.NewNop()}).deadLetter(failedResponse(t, "https://a.example/", 200, ""), "ignored")
+}
@@ -0,0 +1,43 @@
+package crawler
+
+import "sync"
+
+// depthStats counts crawled pages per link depth, seeds are depth 1
+type depthStats struct {
+	mu     sync.Mutex
+	counts map[int]int
+}
+
+func newDepthStats() *depthStats {
+	return &depthStats{counts: make(map[int]int)}
+}
+
+func (d *depthStats) record(depth int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.counts[depth]++
+}
+
+func (d *depthStats) snapshot() map[int]int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	out := make(map[int]int, len(d.counts))
+	for depth, count := range d.counts {
+		out[depth] = count
+	}
+	return out
+}
+
+// DepthHistogram returns how many pages the last finished crawl session
+// fetched at each depth
+func (w *Crawler) DepthHistogram() map[int]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out := make(map[int]int, len(w.lastDepths))
+	for depth, count := range w.lastDepths {
+		out[depth] = count
+	}
+	return out
+}
@@ -2,7 +2,7 @@ package crawler
 
 import (
 	"bytes"
-	"context"
+	"fmt"
 	"net/url"
 	"regexp"
 	"strings"
@@ -14,31 +14,122 @@ import (
 	"go.uber.org/zap"
 )
 
-func (w *Crawler) OnHTML() colly.HTMLCallback {
+func (w *crawlJob) OnHTML() colly.HTMLCallback {
 	return func(e *colly.HTMLElement) {
-		href := e.Attr("href")
-		absoluteURL := e.Request.AbsoluteURL(href)
-		if shouldSkipURL(absoluteURL) {
-			w.logger.Debug("skipping low-value URL", zap.String("url", absoluteURL))
-			return
+		// links whose anchor text or URL match the topic are visited first,
+		// so they survive the per-page cap
+		var relevant, others []string
+		seen := make(map[string]struct{})
+		// the page itself may be off the seed's domain after a redirect
+		seedDomain := e.Request.Ctx.Get(seedDomainKey)
+		if seedDomain == "" {
+			seedDomain = e.Request.URL.Hostname()
+		}
+
+		e.ForEach("a[href]", func(_ int, link *colly.HTMLElement) {
+			absoluteURL := e.Request.AbsoluteURL(link.Attr("href"))
+			if absoluteURL == "" {
+				return
+			}
+			if _, ok := seen[absoluteURL]; ok {
+				return
+			}
+			seen[absoluteURL] = struct{}{}
+
+			if shouldSkipURL(absoluteURL) {
+				w.logger.Debug("skipping low-value URL", logURL(absoluteURL))
+				return
+			}
+			if w.sameDomainOnly && !isSameDomainLink(seedDomain, absoluteURL) {
+				w.logger.Debug("skipping cross-domain URL", logURL(absoluteURL))
+				return
+			}
+			if w.topic != "" && isTopicRelevant(link.Text+" "+absoluteURL, w.topic) {
+				relevant = append(relevant, absoluteURL)
+			} else {
+				others = append(others, absoluteURL)
+			}
+		})
+
+		links := append(relevant, others...)
+		if w.maxLinksPerPage > 0 && len(links) > w.maxLinksPerPage {
+			w.logger.Info("fan-out capped",
+				logURL(e.Request.URL.String()),
+				zap.Int("links_found", len(links)),
+				zap.Int("links_dropped", len(links)-w.maxLinksPerPage))
+			links = links[:w.maxLinksPerPage]
 		}
-		if err := e.Request.Visit(absoluteURL); err != nil {
-			w.logger.Error("Error visit", zap.String("url", absoluteURL), zap.Error(err))
+
+		// file hosts such as booksdl.lc answer with a 403 page unless the
+		// Referer matches the page that linked the download
+		referer := e.Request.URL.String()
+		for _, link := range links {
+			w.referers.Store(link, referer)
+			if err := e.Request.Visit(link); err != nil {
+				w.referers.Delete(link)
+				w.logger.Error("Error visit", logURL(link), zap.Error(err))
+			}
 		}
 	}
 }
 
-func (w *Crawler) OnRequest() colly.RequestCallback {
+func (w *crawlJob) OnRequest() colly.RequestCallback {
 	return (func(r *colly.Request) {
+		if w.ctx.Err() != nil {
+			r.Abort()
+			return
+		}
+		if referer, ok := w.referers.LoadAndDelete(r.URL.String()); ok {
+			r.Headers.Set("Referer", referer.(string))
+		}
 		if shouldSkipURL(r.URL.String()) {
-			w.logger.Warn("skip visit", zap.String("url", r.URL.String()))
+			w.logger.Warn("skip visit", logURL(r.URL.String()))
 			r.Abort()
+			return
+		}
+		if !w.isAllowedDomain(r.URL.Hostname()) {
+			w.logger.Debug("domain not allowed", logURL(r.URL.String()))
+			r.Abort()
+			return
+		}
+
+		data, err := r.Marshal()
+		if err != nil {
+			w.logger.Error("failed to serialize request", logURL(r.URL.String()), zap.Error(err))
+			return
+		}
+		if err := w.storage.AddToFrontier(r.URL.String(), data); err != nil {
+			w.logger.Error("failed to add to frontier", logURL(r.URL.String()), zap.Error(err))
+			return
 		}
+		w.frontierKeys.Store(r.ID, r.URL.String())
 	})
 }
 
-var skipPattern = regexp.MustCompile(`(?i)(contact|privacy|terms|faq|tag|archive|about|signin|login|register|
-subscribe|feedback|cookies|sitemap|help|introduction|portal|events|community|search|changes|contribution)`)
+func (w *crawlJob) OnScraped() colly.ScrapedCallback {
+	return func(r *colly.Response) {
+		w.removeFromFrontier(r.Request)
+	}
+}
+
+// removeFromFrontier drops the frontier entry of r, stored under the URL r
+// had before any redirect
+func (w *crawlJob) removeFromFrontier(r *colly.Request) {
+	key, ok := w.frontierKeys.LoadAndDelete(r.ID)
+	if !ok {
+		return
+	}
+	url := key.(string)
+	if err := w.storage.RemoveFromFrontier(url); err != nil {
+		w.logger.Error("failed to remove from frontier", logURL(url), zap.Error(err))
+	}
+}
+
+var skipPattern = regexp.MustCompile(`(?i)(` + strings.Join([]string{
+	"contact", "privacy", "terms", "faq", "tag", "archive", "about", "signin", "login", "register",
+	"subscribe", "feedback", "cookies", "sitemap", "help", "introduction", "portal", "events",
+	"community", "search", "changes", "contribution",
+}, "|") + `)`)
 
 func shouldSkipURL(rawURL string) bool {
 	parsed, err := url.Parse(rawURL)
@@ -53,78 +144,97 @@ func shouldSkipURL(rawURL string) bool {
 	return skipPattern.MatchString(path)
 }
 
-func (w *Crawler) OnError(collector *colly.Collector) colly.ErrorCallback {
+func (w *crawlJob) OnError() colly.ErrorCallback {
 	return func(r *colly.Response, err error) {
 		w.logger.Info("onerror: " + err.Error())
+		w.removeFromFrontier(r.Request)
 	}
 }
 
-func (w *Crawler) OnResponse() colly.ResponseCallback {
+func (w *crawlJob) OnResponse() colly.ResponseCallback {
 	return func(r *colly.Response) {
 		url := r.Request.URL.String()
+		w.depthStats.record(r.Request.Depth)
 		w.logger.Info("url",
-			zap.String("url", url),
+			logURL(url),
+			zap.Int("depth", r.Request.Depth),
 			zap.Int("body_len", len(r.Body)))
 
 		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(r.Body))
 		if err != nil {
-			w.logger.Error("failed to parse document", zap.String("url", url), zap.Error(err))
+			w.logger.Error("failed to parse document", logURL(url), zap.Error(err))
+			w.deadLetter(r, "parse failed: "+err.Error())
 			return
 		}
 
 		isMetaRelevant := w.isMetaRelevant(doc, w.topic)
 		if !isMetaRelevant {
-			w.logger.Info("meta not relevant", zap.String("url", url))
+			w.logger.Info("meta not relevant", logURL(url))
 			return
 		}
 
-		content, err := w.ExtractText(r.Body, url)
+		content, err := w.ExtractText(r.Body, url, w.qualityEvaluator())
 		if err != nil {
 			w.logger.Error("failed to clean HTML",
-				zap.String("url", url),
+				logURL(url),
 				zap.Error(err))
+			w.deadLetter(r, "extraction failed: "+err.Error())
 			return
 		}
-		if content == nil {
+		if !content.Quality.Accepted {
+			w.logger.Info("below quality threshold",
+				logURL(url),
+				zap.String("reason", content.Quality.Reason))
+			w.deadLetter(r, "below quality threshold: "+content.Quality.Reason)
 			return
 		}
 
 		w.logger.Info("result",
-			zap.String("url", url),
+			logURL(url),
 			zap.String("sitename", content.Metadata.SiteName),
 			zap.String("title", content.Metadata.Title),
 		)
 
-		chunks, err := w.chunkingClient.ChunkText(content.TextMd, w.chunkMethod)
+		count, err := w.indexContent(url, content)
 		if err != nil {
-			w.logger.Error("failed to chunk text",
-				zap.String("url", url),
+			w.logger.Error("failed to index page",
+				logURL(url),
 				zap.Error(err))
 			return
 		}
+		w.logger.Info("inserted chunks",
+			logURL(url),
+			zap.Int("chunk_count", count),
+		)
+	}
+}
 
-		for chunkIndex, chunk := range chunks {
-			err := w.crawlVector.InsertOne(context.Background(), &CrawlVectorDoc{
-				URL:              url,
-				Content:          chunk.Text,
-				ContentEmbedding: chunk.Vector,
-				CrawledAt:        time.Now(),
-			})
-			if err != nil {
-				w.logger.Error("failed to insert chunk",
-					zap.String("url", url),
-					zap.Int("chunk_index", chunkIndex),
-					zap.Error(err))
-			} else {
-				w.logger.Info("inserted chunk",
-					zap.String("url", url),
-					zap.Int("chunk_index", chunkIndex),
-					zap.Int("chunk_length", len(chunk.Text)),
-					zap.Int("vector_dim", len(chunk.Vector)),
-				)
-			}
-		}
+// indexContent chunks the accepted content of url and stores the chunks in
+// place of the ones of its previous crawl, returning how many were stored
+func (w *crawlJob) indexContent(url string, content *Content) (int, error) {
+	chunks, err := w.chunkingClient.ChunkText(w.ctx, content.TextMd, w.chunkMethod)
+	if err != nil {
+		return 0, fmt.Errorf("failed to chunk text: %w", err)
+	}
+
+	crawledAt := time.Now()
+	docs := make([]*CrawlVectorDoc, 0, len(chunks))
+	for _, chunk := range chunks {
+		docs = append(docs, &CrawlVectorDoc{
+			URL:              url,
+			Title:            content.Metadata.Title,
+			SiteName:         content.Metadata.SiteName,
+			Content:          chunk.Text,
+			ContentEmbedding: chunk.Vector,
+			CrawledAt:        crawledAt,
+			QualityScore:     content.Quality.Metrics.Score,
+		})
+	}
+
+	if err := w.crawlVector.ReplaceByURL(w.ctx, url, docs); err != nil {
+		return 0, fmt.Errorf("failed to index %d chunks: %w", len(docs), err)
 	}
+	return len(docs), nil
 }
 
 func stemWord(word string) string {
@@ -215,7 +325,7 @@ func (w *Crawler) OnHTMLDOMLog() colly.HTMLCallback {
 		})
 
 		w.logger.Info("HTML DOM Structure",
-			zap.String("url", url),
+			logURL(url),
 			zap.Strings("links", links),
 			zap.Int("links_count", len(links)))
 		// zap.Strings("links", links))
@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func indexedPage(text string, score float64) *Content {
+	return &Content{
+		TextMd:   text,
+		Metadata: &ContentMetadata{Title: "Brewing", SiteName: "Homebrew Wiki"},
+		Quality:  &QualityVerdict{Metrics: QualityMetrics{Score: score}, Accepted: true},
+	}
+}
+
+func TestIndexContentReplacesChunksInTheStore(t *testing.T) {
+	srv := newSite(t, nil)
+	c := newTestCrawler(t, srv.Server)
+	store := c.crawlVector.(*fakeStore)
+	job, err := c.newJob(context.Background(), CrawlConfig{ChunkMethod: ChunkMarkdown, Topic: "brew"})
+	if err != nil {
+		t.Fatalf("newJob: %v", err)
+	}
+	page := "https://wiki.example/brewing"
+
+	if n, err := job.indexContent(page, indexedPage("first version", 71)); err != nil || n != 1 {
+		t.Fatalf("indexContent = %d, %v, want 1 chunk", n, err)
+	}
+	if n, err := job.indexContent(page, indexedPage("second version", 80)); err != nil || n != 1 {
+		t.Fatalf("re-index = %d, %v, want 1 chunk", n, err)
+	}
+
+	docs := store.chunks[page]
+	if len(docs) != 1 {
+		t.Fatalf("%d chunks stored for the page, want only the re-indexed one", len(docs))
+	}
+	doc := docs[0]
+	if doc.URL != page || doc.Title != "Brewing" || doc.SiteName != "Homebrew Wiki" ||
+		doc.Content != "second version" || doc.QualityScore != 80 || len(doc.ContentEmbedding) != 1 {
+		t.Fatalf("stored chunk = %+v, want the second version with its metadata", doc)
+	}
+	if doc.CrawledAt.IsZero() {
+		t.Error("crawl time was not set")
+	}
+}
+
+func TestIndexContentKeepsStoreOnChunkingError(t *testing.T) {
+	srv := newSite(t, nil)
+	c := newTestCrawler(t, srv.Server)
+	store := c.crawlVector.(*fakeStore)
+	page := "https://wiki.example/brewing"
+	store.chunks[page] = []*CrawlVectorDoc{{URL: page, Content: "previous"}}
+
+	embedErr := errors.New("embedding service down")
+	c.chunkingClient = fakeChunker{err: embedErr}
+	job, err := c.newJob(context.Background(), CrawlConfig{ChunkMethod: ChunkMarkdown, Topic: "brew"})
+	if err != nil {
+		t.Fatalf("newJob: %v", err)
+	}
+
+	if _, err := job.indexContent(page, indexedPage("new version", 75)); !errors.Is(err, embedErr) {
+		t.Fatalf("indexContent = %v, want the chunking error", err)
+	}
+	if docs := store.chunks[page]; len(docs) != 1 || docs[0].Content != "previous" {
+		t.Fatalf("stored chunks = %+v, want the previous version untouched", docs)
+	}
+}
+
+func TestShouldSkipURL(t *testing.T) {
+	tests := map[string]bool{
+		// the keywords either side of the old line break
+		"https://example.com/register":                       true,
+		"https://example.com/Subscribe/weekly":               true,
+		"https://example.com/privacy_policy":                 true,
+		"https://example.com/wiki/Special:Search":            true,
+		"https://example.com/sitemap.xml":                    true,
+		"https://example.com/wiki/Go_(programming_language)": false,
+		"https://example.com/articles/2024/rust-ownership":   false,
+		// only the path is matched
+		"https://help.example.com/guide?ref=login": false,
+		"://bad": false,
+	}
+	for link, want := range tests {
+		if got := shouldSkipURL(link); got != want {
+			t.Errorf("shouldSkipURL(%q) = %v, want %v", link, got, want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package crawler
+
+import (
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// registeredDomain returns the eTLD+1 of host (en.wikipedia.org -> wikipedia.org),
+// falling back to the host itself for IPs and hosts without a known suffix
+func registeredDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
+
+func sameRegisteredDomain(hostA, hostB string) bool {
+	return registeredDomain(hostA) == registeredDomain(hostB)
+}
+
+func isSameDomainLink(host, link string) bool {
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return sameRegisteredDomain(host, parsed.Hostname())
+}
+
+// isAllowedDomain reports whether host passes the registered-domain
+// whitelist, always true when that matching mode is disabled
+func (w *Crawler) isAllowedDomain(host string) bool {
+	if w.allowedRegisteredDomains == nil {
+		return true
+	}
+	_, ok := w.allowedRegisteredDomains[registeredDomain(host)]
+	return ok
+}
+
+// HostFilter selects search result links by host. A pattern matches the
+// host itself and its subdomains, an empty Include allows every host that is
+// not excluded
+type HostFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (f HostFilter) Allow(rawURL string) bool {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	if matchesAnyHost(host, f.Exclude) {
+		return false
+	}
+	return len(f.Include) == 0 || matchesAnyHost(host, f.Include)
+}
+
+func matchesAnyHost(host string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.ToLower(strings.TrimPrefix(pattern, "."))
+		if host == pattern || strings.HasSuffix(host, "."+pattern) {
+			return true
+		}
+	}
+	return false
+}
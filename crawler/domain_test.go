@@ -0,0 +1,133 @@
+package crawler
+
+import (
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+
+	"axora/pkg/httpclient"
+
+	"go.uber.org/zap"
+)
+
+func TestRegisteredDomain(t *testing.T) {
+	tests := map[string]string{
+		"en.wikipedia.org":  "wikipedia.org",
+		"WikiPedia.org.":    "wikipedia.org",
+		"www.bbc.co.uk":     "bbc.co.uk",
+		"foo.github.io":     "foo.github.io",
+		"localhost":         "localhost",
+		"a.b.c.example.com": "example.com",
+	}
+	for host, want := range tests {
+		if got := registeredDomain(host); got != want {
+			t.Errorf("registeredDomain(%q) = %q, want %q", host, got, want)
+		}
+	}
+}
+
+func TestIsSameDomainLink(t *testing.T) {
+	tests := []struct {
+		host string
+		link string
+		want bool
+	}{
+		{"en.wikipedia.org", "https://de.wikipedia.org/wiki/Go", true},
+		{"wikipedia.org", "https://en.wikipedia.org/", true},
+		{"en.wikipedia.org", "https://wikimedia.org/", false},
+		{"bbc.co.uk", "https://news.bbc.co.uk/", true},
+		{"bbc.co.uk", "https://itv.co.uk/", false},
+		// github.io is a public suffix, every user site is its own domain
+		{"alice.github.io", "https://bob.github.io/", false},
+		{"example.com", "://bad", false},
+	}
+	for _, tt := range tests {
+		if got := isSameDomainLink(tt.host, tt.link); got != tt.want {
+			t.Errorf("isSameDomainLink(%q, %q) = %v, want %v", tt.host, tt.link, got, tt.want)
+		}
+	}
+}
+
+func TestHostFilterAllow(t *testing.T) {
+	filter := HostFilter{
+		Include: []string{"wikipedia.org", ".go.dev"},
+		Exclude: []string{"ads.wikipedia.org"},
+	}
+	tests := map[string]bool{
+		"https://en.wikipedia.org/wiki/Go":     true,
+		"https://WIKIPEDIA.org/":               true,
+		"https://go.dev/doc":                   true,
+		"https://ads.wikipedia.org/click":      false,
+		"https://x.ads.wikipedia.org/click":    false,
+		"https://notwikipedia.org/":            false,
+		"https://example.com/?u=wikipedia.org": false,
+		"://bad":                               false,
+	}
+	for link, want := range tests {
+		if got := filter.Allow(link); got != want {
+			t.Errorf("Allow(%q) = %v, want %v", link, got, want)
+		}
+	}
+
+	excludeOnly := HostFilter{Exclude: []string{"doubleclick.net"}}
+	if !excludeOnly.Allow("https://example.com/") || excludeOnly.Allow("https://ad.doubleclick.net/x") {
+		t.Error("an empty Include must allow every host that is not excluded")
+	}
+}
+
+func TestIsAllowedDomainMatchesSubdomains(t *testing.T) {
+	c, err := NewCrawler(httpclient.DefaultConfig(), zap.NewNop(), newFakeStore(), fakeChunker{},
+		[]string{"www.wikipedia.org", "bbc.co.uk"}, filepath.Join(t.TempDir(), "crawl.db"),
+		0, true, nil, QualityConfig{MinScore: 50})
+	if err != nil {
+		t.Fatalf("NewCrawler: %v", err)
+	}
+	defer c.storage.Close()
+
+	tests := map[string]bool{
+		"en.wikipedia.org":   true,
+		"wikipedia.org":      true,
+		"WWW.Wikipedia.Org.": true,
+		"news.bbc.co.uk":     true,
+		"wikimedia.org":      false,
+		"co.uk":              false,
+		"evil-bbc.co.uk":     false,
+	}
+	for host, want := range tests {
+		if got := c.isAllowedDomain(host); got != want {
+			t.Errorf("isAllowedDomain(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func TestCrawlFollowsSubdomainsOfRegisteredDomain(t *testing.T) {
+	docs := newSite(t, map[string][]string{
+		"/": {"https://blog.example.com/", "https://example.org/"},
+	})
+	blog := newSite(t, map[string][]string{"/": nil})
+	org := newSite(t, map[string][]string{"/": nil})
+	proxy := newConnectProxy(t)
+	proxy.routes["docs.example.com:443"] = docs.Listener.Addr().String()
+	proxy.routes["blog.example.com:443"] = blog.Listener.Addr().String()
+	proxy.routes["example.org:443"] = org.Listener.Addr().String()
+
+	httpConfig := httpclient.DefaultConfig()
+	httpConfig.ProxyURL = proxy.URL
+	httpConfig.TLSConfig = &tls.Config{InsecureSkipVerify: true}
+	c, err := NewCrawler(httpConfig, zap.NewNop(), newFakeStore(), fakeChunker{},
+		[]string{"example.com"}, filepath.Join(t.TempDir(), "crawl.db"),
+		0, true, nil, QualityConfig{MinScore: 50})
+	if err != nil {
+		t.Fatalf("NewCrawler: %v", err)
+	}
+	defer c.storage.Close()
+	c.limit.Delay, c.limit.RandomDelay = 0, 0
+
+	crawl(t, c, CrawlConfig{ChunkMethod: ChunkMarkdown}, "https://docs.example.com/")
+	if docs.hitCount("/") != 1 || blog.hitCount("/") != 1 {
+		t.Fatalf("subdomains fetched %d and %d times, want once each", docs.hitCount("/"), blog.hitCount("/"))
+	}
+	if n := org.hitCount("/"); n != 0 {
+		t.Fatalf("a host outside the registered domain was fetched %d times", n)
+	}
+}
@@ -0,0 +1,178 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.uber.org/zap"
+)
+
+// ErrBrowserUnavailable is returned when headless Chrome cannot be launched,
+// e.g. in minimal containers without a Chrome binary
+var ErrBrowserUnavailable = errors.New("headless browser unavailable")
+
+const (
+	// SearchBackendAuto uses Chrome and falls back to HTTP search when Chrome
+	// cannot be launched
+	SearchBackendAuto = "auto"
+	// SearchBackendChrome only uses Chrome
+	SearchBackendChrome = "chrome"
+	// SearchBackendHTTP only uses HTTP search
+	SearchBackendHTTP = "http"
+)
+
+func ValidateSearchBackend(backend string) error {
+	switch backend {
+	case SearchBackendAuto, SearchBackendChrome, SearchBackendHTTP:
+		return nil
+	default:
+		return fmt.Errorf("unsupported search backend %q, expected %s, %s or %s",
+			backend, SearchBackendAuto, SearchBackendChrome, SearchBackendHTTP)
+	}
+}
+
+const (
+	duckDuckGoHTMLName = "DuckDuckGo HTML"
+	duckDuckGoHTMLURL  = "https://html.duckduckgo.com/html/"
+)
+
+// HTTPSearch collects result URLs from DuckDuckGo's HTML-only endpoint, which
+// needs no JavaScript and so works without a browser
+type HTTPSearch struct {
+	logger     *zap.Logger
+	httpClient *http.Client
+	endpoint   string
+	userAgent  string
+	maxPages   int
+	pageDelay  time.Duration
+}
+
+func NewHTTPSearch(logger *zap.Logger, httpClient *http.Client, maxPages int, pageDelay time.Duration) *HTTPSearch {
+	return &HTTPSearch{
+		logger:     logger,
+		httpClient: httpClient,
+		endpoint:   duckDuckGoHTMLURL,
+		userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 " +
+			"(KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+		maxPages:  maxPages,
+		pageDelay: pageDelay,
+	}
+}
+
+// Collect sends the result URLs of up to maxPages pages to collectedUrls,
+// links rejected by filter are dropped
+func (s *HTTPSearch) Collect(ctx context.Context, query string, filter HostFilter, collectedUrls chan string) error {
+	form := url.Values{"q": {query}}
+	total := 0
+	for page := 1; page <= s.maxPages; page++ {
+		doc, err := s.fetchPage(ctx, form)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", page, err)
+		}
+
+		count := 0
+		doc.Find(".result:not(.result--ad) a.result__a").Each(func(_ int, a *goquery.Selection) {
+			href := resultURL(a.AttrOr("href", ""))
+			if href == "" || !strings.HasPrefix(href, "https") || !filter.Allow(href) {
+				return
+			}
+			select {
+			case collectedUrls <- href:
+				count++
+			case <-ctx.Done():
+			}
+		})
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		total += count
+		s.logger.Info("Collected URLs from page",
+			zap.String("engine", duckDuckGoHTMLName),
+			zap.Int("page", page),
+			zap.Int("urls_this_page", count))
+
+		form = nextPageForm(doc)
+		if form == nil {
+			break
+		}
+		if s.pageDelay > 0 {
+			select {
+			case <-time.After(s.pageDelay):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+
+	s.logger.Info("Collect Urls completed",
+		zap.String("engine", duckDuckGoHTMLName),
+		zap.Int("total_urls", total))
+	return nil
+}
+
+func (s *HTTPSearch) fetchPage(ctx context.Context, form url.Values) (*goquery.Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint,
+		strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("User-Agent", s.userAgent)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	// DuckDuckGo answers rate limited clients with a 202 challenge page
+	if resp.StatusCode == http.StatusAccepted {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil, &BlockedError{
+			Engine: duckDuckGoHTMLName,
+			URL:    s.endpoint,
+			Reason: "challenge page (status 202)",
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return goquery.NewDocumentFromReader(resp.Body)
+}
+
+// resultURL unwraps DuckDuckGo's //duckduckgo.com/l/?uddg=<url> redirect links
+func resultURL(href string) string {
+	parsed, err := url.Parse(href)
+	if err != nil {
+		return ""
+	}
+	if target := parsed.Query().Get("uddg"); target != "" {
+		return target
+	}
+	return href
+}
+
+// nextPageForm returns the fields of the "Next" form, nil on the last page
+func nextPageForm(doc *goquery.Document) url.Values {
+	var form url.Values
+	doc.Find(".nav-link form").EachWithBreak(func(_ int, f *goquery.Selection) bool {
+		if f.Find(`input[type="submit"][value="Next"]`).Length() == 0 {
+			return true
+		}
+		form = url.Values{}
+		f.Find(`input[type="hidden"]`).Each(func(_ int, input *goquery.Selection) {
+			if name := input.AttrOr("name", ""); name != "" {
+				form.Set(name, input.AttrOr("value", ""))
+			}
+		})
+		return false
+	})
+	return form
+}
@@ -0,0 +1,209 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"go.uber.org/zap"
+)
+
+func TestResultURL(t *testing.T) {
+	tests := map[string]string{
+		"//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2Fdoc%3Fa%3D1&rut=x": "https://go.dev/doc?a=1",
+		"https://go.dev/doc":     "https://go.dev/doc",
+		"/l/?kh=-1":              "/l/?kh=-1",
+		"http://[::1]:namedport": "",
+	}
+	for href, want := range tests {
+		if got := resultURL(href); got != want {
+			t.Errorf("resultURL(%q) = %q, want %q", href, got, want)
+		}
+	}
+}
+
+func TestNextPageForm(t *testing.T) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+<div class="nav-link"><form><input type="submit" value="Previous"><input type="hidden" name="s" value="0"></form></div>
+<div class="nav-link"><form>
+  <input type="submit" value="Next">
+  <input type="hidden" name="q" value="golang">
+  <input type="hidden" name="s" value="30">
+  <input type="hidden" value="unnamed">
+</form></div></body></html>`))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	form := nextPageForm(doc)
+	if form.Encode() != "q=golang&s=30" {
+		t.Fatalf("nextPageForm = %v, want the fields of the Next form", form)
+	}
+
+	last, _ := goquery.NewDocumentFromReader(strings.NewReader(
+		`<div class="nav-link"><form><input type="submit" value="Previous"></form></div>`))
+	if form := nextPageForm(last); form != nil {
+		t.Fatalf("nextPageForm on the last page = %v, want nil", form)
+	}
+}
+
+// duckDuckGoPages serves two result pages in DuckDuckGo's HTML layout and
+// records the forms posted to it
+type duckDuckGoPages struct {
+	*httptest.Server
+	mu    sync.Mutex
+	forms []string
+}
+
+func newDuckDuckGoPages(t *testing.T) *duckDuckGoPages {
+	t.Helper()
+	d := &duckDuckGoPages{}
+	d.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		d.mu.Lock()
+		d.forms = append(d.forms, r.PostForm.Encode())
+		d.mu.Unlock()
+
+		if r.PostForm.Get("s") == "" {
+			fmt.Fprint(w, `<html><body>
+<div class="result result--ad"><a class="result__a" href="https://ads.example/buy">Ad</a></div>
+<div class="result"><a class="result__a" href="//duckduckgo.com/l/?uddg=https%3A%2F%2Fgo.dev%2Fdoc">Go</a></div>
+<div class="result"><a class="result__a" href="http://insecure.example/">Plain http</a></div>
+<div class="result"><a class="result__a" href="https://spam.example/">Excluded</a></div>
+<div class="nav-link"><form>
+  <input type="submit" value="Next">
+  <input type="hidden" name="q" value="golang">
+  <input type="hidden" name="s" value="10">
+</form></div></body></html>`)
+			return
+		}
+		fmt.Fprint(w, `<html><body>
+<div class="result"><a class="result__a" href="https://pkg.go.dev/">Packages</a></div>
+</body></html>`)
+	}))
+	t.Cleanup(d.Close)
+	return d
+}
+
+func newTestHTTPSearch(endpoint string, maxPages int) *HTTPSearch {
+	s := NewHTTPSearch(zap.NewNop(), http.DefaultClient, maxPages, 0)
+	s.endpoint = endpoint
+	return s
+}
+
+func collectAll(t *testing.T, collect func(ch chan string) error) ([]string, error) {
+	t.Helper()
+	ch := make(chan string, 20)
+	err := collect(ch)
+	close(ch)
+	var urls []string
+	for u := range ch {
+		urls = append(urls, u)
+	}
+	return urls, err
+}
+
+func TestHTTPSearchCollect(t *testing.T) {
+	pages := newDuckDuckGoPages(t)
+	s := newTestHTTPSearch(pages.URL, 5)
+	filter := HostFilter{Exclude: []string{"spam.example"}}
+
+	urls, err := collectAll(t, func(ch chan string) error {
+		return s.Collect(context.Background(), "golang", filter, ch)
+	})
+	if err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if fmt.Sprint(urls) != "[https://go.dev/doc https://pkg.go.dev/]" {
+		t.Fatalf("collected %v, want the unwrapped results of both pages", urls)
+	}
+	if fmt.Sprint(pages.forms) != "[q=golang q=golang&s=10]" {
+		t.Fatalf("posted forms %v, want the query then the Next form", pages.forms)
+	}
+
+	// maxPages stops before the Next form is followed
+	pages.forms = nil
+	if _, err := collectAll(t, func(ch chan string) error {
+		return newTestHTTPSearch(pages.URL, 1).Collect(context.Background(), "golang", filter, ch)
+	}); err != nil {
+		t.Fatalf("Collect: %v", err)
+	}
+	if len(pages.forms) != 1 {
+		t.Fatalf("%d pages fetched with maxPages 1", len(pages.forms))
+	}
+}
+
+func TestHTTPSearchReportsChallengePage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprint(w, "anomaly detected")
+	}))
+	defer srv.Close()
+
+	err := newTestHTTPSearch(srv.URL, 3).Collect(context.Background(), "golang", HostFilter{}, make(chan string, 1))
+	var blocked *BlockedError
+	if !errors.As(err, &blocked) || blocked.Engine != duckDuckGoHTMLName {
+		t.Fatalf("Collect = %v, want a BlockedError", err)
+	}
+}
+
+func TestWithFallback(t *testing.T) {
+	pages := newDuckDuckGoPages(t)
+	chromeFailed := fmt.Errorf("%w: exec: no chrome", ErrBrowserUnavailable)
+	navigationFailed := errors.New("navigation failed")
+
+	tests := []struct {
+		name       string
+		backend    string
+		chromeErr  error
+		wantChrome bool
+		wantHTTP   bool
+		wantErr    error
+	}{
+		{"http backend skips chrome", SearchBackendHTTP, nil, false, true, nil},
+		{"auto falls back when chrome is missing", SearchBackendAuto, chromeFailed, true, true, nil},
+		{"auto keeps other chrome errors", SearchBackendAuto, navigationFailed, true, false, navigationFailed},
+		{"chrome backend never falls back", SearchBackendChrome, chromeFailed, true, false, ErrBrowserUnavailable},
+	}
+	for _, tt := range tests {
+		b := NewBrowser(zap.NewNop(), "", "")
+		b.SearchBackend = tt.backend
+		b.HTTPSearch = newTestHTTPSearch(pages.URL, 1)
+
+		calledChrome := false
+		urls, err := collectAll(t, func(ch chan string) error {
+			return b.withFallback(context.Background(), "golang", ch, func(context.Context) error {
+				calledChrome = true
+				return tt.chromeErr
+			})
+		})
+		if calledChrome != tt.wantChrome {
+			t.Errorf("%s: chrome called = %v, want %v", tt.name, calledChrome, tt.wantChrome)
+		}
+		if gotHTTP := len(urls) > 0; gotHTTP != tt.wantHTTP {
+			t.Errorf("%s: http search used = %v, want %v", tt.name, gotHTTP, tt.wantHTTP)
+		}
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateSearchBackend(t *testing.T) {
+	for _, backend := range []string{SearchBackendAuto, SearchBackendChrome, SearchBackendHTTP} {
+		if err := ValidateSearchBackend(backend); err != nil {
+			t.Errorf("ValidateSearchBackend(%q): %v", backend, err)
+		}
+	}
+	if err := ValidateSearchBackend("firefox"); err == nil {
+		t.Error("ValidateSearchBackend accepted an unknown backend")
+	}
+}
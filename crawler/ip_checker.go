@@ -2,13 +2,46 @@ package crawler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 )
 
-func GetPublicIP(ctx context.Context, httpClient *http.Client) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.ipify.org?format=text", nil)
+// DefaultIPCheckEndpoints are tried in order until one returns a valid IP.
+// httpbin.org is left out as it is often rate limited or down
+var DefaultIPCheckEndpoints = []string{
+	"https://api.ipify.org?format=json",
+	"https://ifconfig.me/ip",
+	"https://icanhazip.com",
+}
+
+// GetPublicIP returns the public IP seen by the first endpoint that answers,
+// endpoints defaults to DefaultIPCheckEndpoints when empty
+func GetPublicIP(ctx context.Context, httpClient *http.Client, endpoints []string) (string, error) {
+	if len(endpoints) == 0 {
+		endpoints = DefaultIPCheckEndpoints
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		ip, err := fetchIP(ctx, httpClient, endpoint)
+		if err == nil {
+			return ip, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return "", errors.Join(errs...)
+}
+
+func fetchIP(ctx context.Context, httpClient *http.Client, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return "", err
 	}
@@ -20,16 +53,37 @@ func GetPublicIP(ctx context.Context, httpClient *http.Client) (string, error) {
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return "", nil
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d", resp.StatusCode)
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
 	if err != nil {
 		return "", err
 	}
 
+	return parseIP(body)
+}
+
+// parseIP accepts httpbin's {"origin": "..."}, ipify's {"ip": "..."} and
+// plain text bodies
+func parseIP(body []byte) (string, error) {
 	ipStr := strings.TrimSpace(string(body))
 
+	var payload struct {
+		Origin string `json:"origin"`
+		IP     string `json:"ip"`
+	}
+	if err := json.Unmarshal(body, &payload); err == nil {
+		ipStr = payload.IP
+		if payload.Origin != "" {
+			// httpbin lists every hop when behind proxies, the first is the client
+			ipStr = strings.TrimSpace(strings.Split(payload.Origin, ",")[0])
+		}
+	}
+
+	if net.ParseIP(ipStr) == nil {
+		return "", fmt.Errorf("invalid IP in response: %q", ipStr)
+	}
 	return ipStr, nil
 }
@@ -0,0 +1,89 @@
+package crawler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseIP(t *testing.T) {
+	tests := []struct {
+		body string
+		want string // "" when the body must be rejected
+	}{
+		{`{"origin": "203.0.113.7"}`, "203.0.113.7"},
+		{`{"origin": "203.0.113.7, 10.0.0.1"}`, "203.0.113.7"},
+		{`{"ip":"2001:db8::1"}`, "2001:db8::1"},
+		{"198.51.100.4\n", "198.51.100.4"},
+		{`{"origin": "not an ip"}`, ""},
+		{`{}`, ""},
+		{"<html>rate limited</html>", ""},
+	}
+	for _, tt := range tests {
+		got, err := parseIP([]byte(tt.body))
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("parseIP(%q) = %q, want an error", tt.body, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseIP(%q) = %q, %v, want %q", tt.body, got, err, tt.want)
+		}
+	}
+}
+
+func TestGetPublicIPFallsBackAcrossEndpoints(t *testing.T) {
+	var calls []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.URL.Path)
+		switch r.URL.Path {
+		case "/down":
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+		case "/garbage":
+			_, _ = w.Write([]byte("<html>maintenance</html>"))
+		default:
+			_, _ = w.Write([]byte(`{"origin": "203.0.113.7"}`))
+		}
+	}))
+	defer srv.Close()
+
+	ip, err := GetPublicIP(context.Background(), srv.Client(),
+		[]string{srv.URL + "/down", srv.URL + "/garbage", srv.URL + "/ip", srv.URL + "/unused"})
+	if err != nil {
+		t.Fatalf("GetPublicIP: %v", err)
+	}
+	if ip != "203.0.113.7" {
+		t.Fatalf("ip = %q, want 203.0.113.7", ip)
+	}
+	if strings.Join(calls, ",") != "/down,/garbage,/ip" {
+		t.Fatalf("endpoints called %v, want them in order up to the first success", calls)
+	}
+}
+
+func TestGetPublicIPReportsEveryFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "down", http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	_, err := GetPublicIP(context.Background(), srv.Client(), []string{srv.URL + "/a", srv.URL + "/b"})
+	if err == nil {
+		t.Fatal("GetPublicIP succeeded with every endpoint down")
+	}
+	for _, endpoint := range []string{"/a", "/b"} {
+		if !strings.Contains(err.Error(), srv.URL+endpoint) {
+			t.Errorf("error %q does not mention %s", err, endpoint)
+		}
+	}
+}
+
+func TestDefaultIPCheckEndpointsAvoidHttpbin(t *testing.T) {
+	for _, endpoint := range DefaultIPCheckEndpoints {
+		if strings.Contains(endpoint, "httpbin.org") {
+			t.Errorf("default endpoint %s depends on httpbin", endpoint)
+		}
+	}
+}
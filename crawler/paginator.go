@@ -0,0 +1,131 @@
+package crawler
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"go.uber.org/zap"
+)
+
+// Paginator walks the result pages of a search engine in an open browser tab
+// by clicking the engine's next button, so supporting a new engine only takes
+// a SearchEngine entry
+type Paginator struct {
+	logger   *zap.Logger
+	engine   SearchEngine
+	maxPages int
+	delay    time.Duration
+}
+
+func NewPaginator(logger *zap.Logger, engine SearchEngine, maxPages int, delay time.Duration) *Paginator {
+	return &Paginator{
+		logger:   logger,
+		engine:   engine,
+		maxPages: maxPages,
+		delay:    delay,
+	}
+}
+
+// PageFunc processes the page currently shown, page starts at 1
+type PageFunc func(ctx context.Context, page int) error
+
+// Run calls visit for the current page and every following one until
+// maxPages is reached, the next button is missing or disabled, visit fails or
+// ctx is done. It returns the number of pages visited
+func (p *Paginator) Run(ctx context.Context, visit PageFunc) (int, error) {
+	page := 0
+	for page < p.maxPages {
+		page++
+		p.logger.Info("Processing page",
+			zap.Int("current_page", page),
+			zap.Int("max_pages", p.maxPages),
+			zap.String("engine", p.engine.Name))
+
+		if err := visit(ctx, page); err != nil {
+			return page, err
+		}
+
+		if page >= p.maxPages {
+			p.logger.Info("Reached maximum pages", zap.Int("max_pages", p.maxPages))
+			break
+		}
+
+		hasNext, err := p.next(ctx)
+		if err != nil {
+			p.logger.Error("Failed to navigate to next page",
+				zap.Error(err),
+				zap.Int("current_page", page))
+			return page, err
+		}
+		if !hasNext {
+			p.logger.Info("No more pages available", zap.Int("final_page", page))
+			break
+		}
+
+		if p.delay > 0 {
+			select {
+			case <-time.After(p.delay):
+			case <-ctx.Done():
+				return page, ctx.Err()
+			}
+		}
+	}
+	return page, nil
+}
+
+// next clicks the next button, reporting false when there is none to click
+func (p *Paginator) next(ctx context.Context) (bool, error) {
+	selector := p.engine.NextPageSelector
+
+	// AtLeast(0) returns at once when the button is missing instead of
+	// waiting for it until ctx expires
+	var nodes []*cdp.Node
+	err := chromedp.Run(ctx,
+		chromedp.Nodes(selector, &nodes, chromedp.ByQuery, chromedp.AtLeast(0)),
+	)
+	if err != nil {
+		return false, err
+	}
+	if len(nodes) == 0 {
+		return false, nil
+	}
+	if isDisabled(nodes[0]) {
+		p.logger.Debug("Next page button is disabled", zap.String("selector", selector))
+		return false, nil
+	}
+
+	p.logger.Debug("Clicking next page button", zap.String("selector", selector))
+
+	if err := chromedp.Run(ctx, chromedp.WaitVisible(selector, chromedp.ByQuery)); err != nil {
+		return false, nil
+	}
+
+	err = chromedp.Run(ctx,
+		chromedp.Click(selector, chromedp.ByQuery),
+		chromedp.Sleep(2*time.Second),
+		chromedp.WaitReady("body"),
+	)
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isDisabled covers the ways engines grey out the next button on the last page
+func isDisabled(node *cdp.Node) bool {
+	if _, ok := node.Attribute("disabled"); ok {
+		return true
+	}
+	if node.AttributeValue("aria-disabled") == "true" {
+		return true
+	}
+	for _, class := range strings.Fields(node.AttributeValue("class")) {
+		if strings.Contains(class, "disabled") {
+			return true
+		}
+	}
+	return false
+}
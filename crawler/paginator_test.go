@@ -0,0 +1,122 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/chromedp"
+	"go.uber.org/zap"
+)
+
+// newTestTab launches headless Chrome the way the Browser does and skips the
+// test when there is no Chrome to launch
+func newTestTab(t *testing.T) (*Browser, context.Context) {
+	t.Helper()
+	b := NewBrowser(zap.NewNop(), "", "")
+	ctx, cancel, err := b.setupBrowserContext(context.Background(), time.Minute)
+	if errors.Is(err, ErrBrowserUnavailable) {
+		t.Skipf("chrome unavailable: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("setupBrowserContext: %v", err)
+	}
+	t.Cleanup(cancel)
+	return b, ctx
+}
+
+func TestIsDisabled(t *testing.T) {
+	tests := []struct {
+		attributes []string
+		want       bool
+	}{
+		{[]string{"href", "/page/2", "class", "next"}, false},
+		{[]string{"disabled", ""}, true},
+		{[]string{"aria-disabled", "true"}, true},
+		{[]string{"aria-disabled", "false"}, false},
+		{[]string{"class", "pagination-btn btn--disabled"}, true},
+		{[]string{"class", "disabled"}, true},
+	}
+	for _, tt := range tests {
+		if got := isDisabled(&cdp.Node{Attributes: tt.attributes}); got != tt.want {
+			t.Errorf("isDisabled(%v) = %v, want %v", tt.attributes, got, tt.want)
+		}
+	}
+}
+
+// resultPages serves /1 to /last, each linking to the next one, the last
+// page has a disabled next button
+func resultPages(t *testing.T, last int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var page int
+		if _, err := fmt.Sscanf(r.URL.Path, "/%d", &page); err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		next := fmt.Sprintf(`<a id="next" href="/%d">Next</a>`, page+1)
+		if page >= last {
+			next = `<a id="next" class="disabled">Next</a>`
+		}
+		fmt.Fprintf(w, "<html><body><p>page %d</p>%s</body></html>", page, next)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPaginatorRun(t *testing.T) {
+	b, ctx := newTestTab(t)
+	srv := resultPages(t, 3)
+	engine := SearchEngine{Name: "test", NextPageSelector: "#next"}
+
+	tests := []struct {
+		maxPages int
+		want     []string
+	}{
+		{10, []string{"/1", "/2", "/3"}},
+		{2, []string{"/1", "/2"}},
+	}
+	for _, tt := range tests {
+		if err := chromedp.Run(ctx, chromedp.Navigate(srv.URL+"/1")); err != nil {
+			t.Fatalf("navigate: %v", err)
+		}
+
+		var visited []string
+		pages, err := NewPaginator(b.logger, engine, tt.maxPages, 0).Run(ctx, func(ctx context.Context, page int) error {
+			var location string
+			if err := chromedp.Run(ctx, chromedp.Location(&location)); err != nil {
+				return err
+			}
+			visited = append(visited, location[len(srv.URL):])
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("maxPages %d: Run: %v", tt.maxPages, err)
+		}
+		if pages != len(tt.want) || fmt.Sprint(visited) != fmt.Sprint(tt.want) {
+			t.Errorf("maxPages %d: visited %d pages %v, want %v", tt.maxPages, pages, visited, tt.want)
+		}
+	}
+}
+
+func TestPaginatorStopsOnVisitError(t *testing.T) {
+	b, ctx := newTestTab(t)
+	srv := resultPages(t, 3)
+	if err := chromedp.Run(ctx, chromedp.Navigate(srv.URL+"/1")); err != nil {
+		t.Fatalf("navigate: %v", err)
+	}
+
+	failed := errors.New("extraction failed")
+	engine := SearchEngine{Name: "test", NextPageSelector: "#next"}
+	pages, err := NewPaginator(b.logger, engine, 10, 0).Run(ctx, func(context.Context, int) error {
+		return failed
+	})
+	if !errors.Is(err, failed) || pages != 1 {
+		t.Fatalf("Run = %d, %v, want 1 page and the visit error", pages, err)
+	}
+}
@@ -0,0 +1,141 @@
+package crawler
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// QualityMetrics are the measurements behind a page's quality score
+type QualityMetrics struct {
+	WordCount         int
+	VocabRichness     float64
+	SentenceCount     int
+	AvgSentenceLength float64
+	HTMLSize          int
+	TextSize          int
+	Score             float64
+}
+
+// QualityVerdict is the outcome of evaluating a page, Reason explains a
+// rejection
+type QualityVerdict struct {
+	Metrics  QualityMetrics
+	Accepted bool
+	Reason   string
+}
+
+// QualityEvaluator is the single place deciding whether extracted content is
+// worth indexing
+type QualityEvaluator struct {
+	MinScore float64
+	Weights  QualityWeights
+}
+
+// qualityEvaluator uses the configured threshold with the weights of the
+// crawl
+func (w *crawlJob) qualityEvaluator() QualityEvaluator {
+	return QualityEvaluator{MinScore: w.quality.MinScore, Weights: w.qualityWeights}
+}
+
+var sentenceSplit = regexp.MustCompile(`[.!?]+`)
+
+func (e QualityEvaluator) Evaluate(content *Content) QualityVerdict {
+	words := strings.Fields(content.TextContent)
+	metrics := QualityMetrics{
+		WordCount: len(words),
+		HTMLSize:  len(content.HtmlNode),
+		TextSize:  len(content.TextContent),
+	}
+	if len(words) == 0 {
+		// nothing to score, and richness would divide by zero
+		return QualityVerdict{Metrics: metrics, Reason: "no text extracted"}
+	}
+
+	unique := make(map[string]struct{}, len(words))
+	for _, w := range words {
+		w = strings.ToLower(strings.Trim(w, ".,!?\"'():;[]{}"))
+		if w != "" {
+			unique[w] = struct{}{}
+		}
+	}
+	metrics.VocabRichness = float64(len(unique)) / float64(len(words))
+
+	metrics.SentenceCount = len(sentenceSplit.Split(content.TextContent, -1))
+	metrics.AvgSentenceLength = float64(metrics.WordCount) / float64(metrics.SentenceCount)
+
+	metrics.Score = qualityScore(
+		lengthScore(metrics.WordCount),
+		richnessScore(metrics.VocabRichness),
+		sentenceScore(metrics.SentenceCount, metrics.AvgSentenceLength),
+		e.Weights)
+	if metrics.Score < e.MinScore {
+		return QualityVerdict{
+			Metrics: metrics,
+			Reason:  fmt.Sprintf("score %.1f below threshold %.1f", metrics.Score, e.MinScore),
+		}
+	}
+	return QualityVerdict{Metrics: metrics, Accepted: true}
+}
+
+func lengthScore(wordCount int) float64 {
+	switch {
+	case wordCount < 200:
+		return 0.0
+	case wordCount > 10000:
+		return 0.7
+	default:
+		return 1.0 // ideal range
+	}
+}
+
+func richnessScore(vocabRichness float64) float64 {
+	switch {
+	case vocabRichness < 0.25:
+		return 0.0
+	case vocabRichness > 0.6:
+		return 0.8
+	default:
+		return 1.0
+	}
+}
+
+func sentenceScore(sentenceCount int, avgSentenceLength float64) float64 {
+	if sentenceCount < 5 {
+		return 0.0
+	}
+	if avgSentenceLength < 10 || avgSentenceLength > 30 {
+		return 0.7
+	}
+	return 1.0
+}
+
+// QualityWeights sets how much the length, vocabulary richness and sentence
+// scores contribute to the final quality score
+type QualityWeights struct {
+	Length   float64 `json:"length"`
+	Richness float64 `json:"richness"`
+	Sentence float64 `json:"sentence"`
+}
+
+var DefaultQualityWeights = QualityWeights{Length: 0.50, Richness: 0.30, Sentence: 0.20}
+
+// Normalize validates the weights and scales them to sum to 1.0
+func (q QualityWeights) Normalize() (QualityWeights, error) {
+	if q.Length < 0 || q.Richness < 0 || q.Sentence < 0 {
+		return QualityWeights{}, fmt.Errorf("quality weights must be non-negative: %+v", q)
+	}
+	sum := q.Length + q.Richness + q.Sentence
+	if sum == 0 {
+		return QualityWeights{}, fmt.Errorf("quality weights must not all be zero")
+	}
+	return QualityWeights{
+		Length:   q.Length / sum,
+		Richness: q.Richness / sum,
+		Sentence: q.Sentence / sum,
+	}, nil
+}
+
+func qualityScore(length, richness, sentence float64, weights QualityWeights) float64 {
+	return (weights.Length*length + weights.Richness*richness + weights.Sentence*sentence) * 100
+}
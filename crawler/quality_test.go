@@ -0,0 +1,192 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// borderlinePage has over 200 distinct words in a single sentence, so it
+// scores full length, 0.8 richness and no sentence points
+func borderlinePage() *Content {
+	words := make([]string, 250)
+	for i := range words {
+		words[i] = fmt.Sprintf("word%d", i)
+	}
+	text := strings.Join(words, " ")
+	return &Content{HtmlNode: "<p>" + text + "</p>", TextContent: text}
+}
+
+func TestQualityWeightsNormalize(t *testing.T) {
+	got, err := QualityWeights{Length: 2, Richness: 1, Sentence: 1}.Normalize()
+	if err != nil {
+		t.Fatalf("Normalize: %v", err)
+	}
+	if got != (QualityWeights{Length: 0.5, Richness: 0.25, Sentence: 0.25}) {
+		t.Fatalf("Normalize = %+v, want 0.5/0.25/0.25", got)
+	}
+
+	got, err = DefaultQualityWeights.Normalize()
+	if err != nil || math.Abs(got.Length+got.Richness+got.Sentence-1) > 1e-9 {
+		t.Fatalf("default weights normalize to %+v, %v", got, err)
+	}
+
+	for _, invalid := range []QualityWeights{
+		{Length: -0.1, Richness: 1, Sentence: 1},
+		{},
+	} {
+		if _, err := invalid.Normalize(); err == nil {
+			t.Errorf("Normalize(%+v) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestCustomWeightsChangeTheVerdict(t *testing.T) {
+	page := borderlinePage()
+	tests := []struct {
+		name    string
+		weights QualityWeights
+		want    bool
+	}{
+		{"default weights", DefaultQualityWeights, false},
+		{"length only", QualityWeights{Length: 1}, true},
+		{"sentence only", QualityWeights{Sentence: 1}, false},
+	}
+	for _, tt := range tests {
+		weights, err := tt.weights.Normalize()
+		if err != nil {
+			t.Fatalf("%s: Normalize: %v", tt.name, err)
+		}
+		verdict := QualityEvaluator{MinScore: 80, Weights: weights}.Evaluate(page)
+		if verdict.Accepted != tt.want {
+			t.Errorf("%s: accepted = %v with score %.1f, want %v",
+				tt.name, verdict.Accepted, verdict.Metrics.Score, tt.want)
+		}
+	}
+}
+
+func TestCrawlJobUsesRequestWeights(t *testing.T) {
+	srv := newSite(t, nil)
+	c := newTestCrawler(t, srv.Server)
+
+	job, err := c.newJob(context.Background(), CrawlConfig{
+		ChunkMethod:    ChunkMarkdown,
+		QualityWeights: &QualityWeights{Length: 3, Richness: 1},
+	})
+	if err != nil {
+		t.Fatalf("newJob: %v", err)
+	}
+	evaluator := job.qualityEvaluator()
+	if evaluator.Weights != (QualityWeights{Length: 0.75, Richness: 0.25}) || evaluator.MinScore != 50 {
+		t.Fatalf("evaluator = %+v, want the normalized request weights and the configured threshold", evaluator)
+	}
+
+	// invalid weights fall back to the configured ones
+	job, err = c.newJob(context.Background(), CrawlConfig{
+		ChunkMethod:    ChunkMarkdown,
+		QualityWeights: &QualityWeights{Length: -1},
+	})
+	if err != nil {
+		t.Fatalf("newJob: %v", err)
+	}
+	if got := job.qualityEvaluator().Weights; got != c.quality.Weights {
+		t.Fatalf("weights = %+v, want the configured %+v", got, c.quality.Weights)
+	}
+}
+
+func TestEvaluateAppliesThreshold(t *testing.T) {
+	page := borderlinePage() // scores 74 with the default weights
+	for _, tt := range []struct {
+		minScore float64
+		want     bool
+	}{
+		{0, true},
+		{70, true},
+		{80, false},
+		{100, false},
+	} {
+		verdict := QualityEvaluator{MinScore: tt.minScore, Weights: DefaultQualityWeights}.Evaluate(page)
+		if verdict.Accepted != tt.want {
+			t.Errorf("min score %v: accepted = %v with score %.1f, want %v",
+				tt.minScore, verdict.Accepted, verdict.Metrics.Score, tt.want)
+		}
+		if !verdict.Accepted && !strings.Contains(verdict.Reason, "below threshold") {
+			t.Errorf("min score %v: reason = %q, want the threshold named", tt.minScore, verdict.Reason)
+		}
+	}
+}
+
+func TestEvaluateReturnsMetrics(t *testing.T) {
+	content := &Content{HtmlNode: "<p>The cat sat. The dog ran</p>", TextContent: "The cat sat. The dog ran"}
+	verdict := QualityEvaluator{MinScore: 50, Weights: DefaultQualityWeights}.Evaluate(content)
+
+	m := verdict.Metrics
+	if m.WordCount != 6 || m.SentenceCount != 2 || m.AvgSentenceLength != 3 ||
+		m.HTMLSize != len(content.HtmlNode) || m.TextSize != len(content.TextContent) {
+		t.Fatalf("metrics = %+v", m)
+	}
+	if math.Abs(m.VocabRichness-5.0/6) > 1e-9 {
+		t.Errorf("vocab richness = %v, want 5/6 as \"the\" repeats", m.VocabRichness)
+	}
+	// only richness scores on such a short page
+	if math.Abs(m.Score-24) > 1e-9 {
+		t.Errorf("score = %v, want 24", m.Score)
+	}
+	if verdict.Accepted || verdict.Reason == "" {
+		t.Errorf("verdict = %+v, want a rejection with a reason", verdict)
+	}
+}
+
+func TestEvaluateHandlesTextWithoutWords(t *testing.T) {
+	evaluator := QualityEvaluator{MinScore: 0, Weights: DefaultQualityWeights}
+	for _, text := range []string{"", " \n\t "} {
+		verdict := evaluator.Evaluate(&Content{HtmlNode: "<div></div>", TextContent: text})
+		if verdict.Accepted || verdict.Reason != "no text extracted" {
+			t.Errorf("Evaluate(%q) = %+v, want a no text rejection", text, verdict)
+		}
+		if verdict.Metrics.HTMLSize != len("<div></div>") {
+			t.Errorf("Evaluate(%q) dropped the html size: %+v", text, verdict.Metrics)
+		}
+	}
+
+	// words that are only punctuation leave no vocabulary to divide by
+	verdict := evaluator.Evaluate(&Content{TextContent: "... !!! ??"})
+	m := verdict.Metrics
+	if math.IsNaN(m.VocabRichness) || math.IsNaN(m.AvgSentenceLength) || math.IsNaN(m.Score) {
+		t.Fatalf("metrics = %+v, want no NaN", m)
+	}
+}
+
+func TestQualitySubScores(t *testing.T) {
+	for _, tt := range []struct {
+		words int
+		want  float64
+	}{{0, 0}, {199, 0}, {200, 1}, {10000, 1}, {10001, 0.7}} {
+		if got := lengthScore(tt.words); got != tt.want {
+			t.Errorf("lengthScore(%d) = %v, want %v", tt.words, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		richness float64
+		want     float64
+	}{{0.1, 0}, {0.25, 1}, {0.6, 1}, {0.61, 0.8}} {
+		if got := richnessScore(tt.richness); got != tt.want {
+			t.Errorf("richnessScore(%v) = %v, want %v", tt.richness, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		sentences int
+		avgLength float64
+		want      float64
+	}{{4, 20, 0}, {5, 20, 1}, {5, 9, 0.7}, {5, 10, 1}, {5, 30, 1}, {5, 31, 0.7}} {
+		if got := sentenceScore(tt.sentences, tt.avgLength); got != tt.want {
+			t.Errorf("sentenceScore(%d, %v) = %v, want %v", tt.sentences, tt.avgLength, got, tt.want)
+		}
+	}
+
+	if got := qualityScore(1, 1, 1, DefaultQualityWeights); math.Abs(got-100) > 1e-9 {
+		t.Errorf("a perfect page scores %v, want 100", got)
+	}
+}
@@ -0,0 +1,52 @@
+package crawler
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"go.uber.org/zap"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// screenshot saves what the tab currently shows to screenshotDir, so failed
+// navigations and blocks can be inspected afterwards. It is a no-op unless a
+// directory was configured, and failures are only logged since the original
+// error matters more
+func (b *Browser) screenshot(ctx context.Context, engine string, page int, reason string) {
+	if b.screenshotDir == "" {
+		return
+	}
+
+	var buf []byte
+	if err := chromedp.Run(ctx, chromedp.FullScreenshot(&buf, 90)); err != nil {
+		b.logger.Warn("Failed to capture screenshot", zap.Error(err))
+		return
+	}
+
+	if err := os.MkdirAll(b.screenshotDir, 0o755); err != nil {
+		b.logger.Warn("Failed to create screenshot dir", zap.Error(err))
+		return
+	}
+	path := filepath.Join(b.screenshotDir, screenshotName(time.Now(), engine, page, reason))
+	if err := os.WriteFile(path, buf, 0o644); err != nil {
+		b.logger.Warn("Failed to write screenshot", zap.Error(err))
+		return
+	}
+	b.logger.Info("Saved screenshot", zap.String("path", path), zap.String("reason", reason))
+}
+
+// screenshotName builds a file name that sorts by time and is safe on any
+// filesystem whatever the engine name and reason contain
+func screenshotName(at time.Time, engine string, page int, reason string) string {
+	return fmt.Sprintf("%s_%s_page%d_%s.jpg",
+		at.Format("20060102T150405"),
+		unsafeFileChars.ReplaceAllString(engine, "-"),
+		page,
+		unsafeFileChars.ReplaceAllString(reason, "-"))
+}
@@ -0,0 +1,48 @@
+package crawler
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestScreenshotName(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	got := screenshotName(at, "DuckDuckGo HTML", 3, "page-state/../x")
+	if got != "20250102T030405_DuckDuckGo-HTML_page3_page-state-x.jpg" {
+		t.Fatalf("screenshotName = %q", got)
+	}
+}
+
+func TestScreenshotDisabledWithoutDir(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	b := NewBrowser(zap.New(core), "", "")
+
+	// a context without a browser would fail any capture, so nothing
+	// logged means nothing was attempted
+	b.screenshot(context.Background(), "Brave", 1, "navigation")
+	if logs.Len() != 0 {
+		t.Fatalf("screenshot without a dir logged %v", logs.All())
+	}
+}
+
+func TestScreenshotWritesFile(t *testing.T) {
+	b, ctx := newTestTab(t)
+	b.screenshotDir = filepath.Join(t.TempDir(), "shots")
+
+	b.screenshot(ctx, "Brave", 2, "page-state")
+	entries, err := os.ReadDir(b.screenshotDir)
+	if err != nil {
+		t.Fatalf("read screenshot dir: %v", err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), "_Brave_page2_page-state.jpg") {
+		t.Fatalf("screenshot dir holds %v, want one Brave page 2 screenshot", entries)
+	}
+}
@@ -2,12 +2,13 @@ package crawler
 
 import (
 	"bytes"
+	"fmt"
 	"net/url"
-	"regexp"
 	"strings"
 	"time"
 
 	htmltomarkdown "github.com/JohannesKaufmann/html-to-markdown/v2"
+	"github.com/PuerkitoBio/goquery"
 	"github.com/go-shiori/go-readability"
 	"github.com/markusmobius/go-trafilatura"
 	"go.uber.org/zap"
@@ -19,6 +20,7 @@ type Content struct {
 	TextContent string
 	TextMd      string
 	Metadata    *ContentMetadata
+	Quality     *QualityVerdict
 }
 
 type ContentMetadata struct {
@@ -83,7 +85,7 @@ func (w *Crawler) ExtractWithTrafilatura(body []byte, pageURL string) (*Content,
 	wordCount := len(words)
 
 	w.logger.Info("trafilatura_extraction_result",
-		zap.String("url", pageURL),
+		logURL(pageURL),
 		zap.String("title", metadata.Title),
 		zap.String("author", metadata.Author),
 		zap.String("language", metadata.Language),
@@ -123,7 +125,7 @@ func (w *Crawler) ExtractWithReadability(body []byte, pageURL string) (string, e
 	wordCount := len(words)
 
 	w.logger.Info("readability_extraction_result",
-		zap.String("url", pageURL),
+		logURL(pageURL),
 		zap.String("title", article.Title),
 		zap.String("byline", article.Byline),
 		zap.String("excerpt", article.Excerpt),
@@ -135,53 +137,87 @@ func (w *Crawler) ExtractWithReadability(body []byte, pageURL string) (string, e
 	return textContent, nil
 }
 
-func (w *Crawler) ExtractText(body []byte, pageURL string) (*Content, error) {
-	content, err := w.ExtractWithTrafilatura(body, pageURL)
+// QualityConfig controls how pages are cleaned and scored before indexing
+type QualityConfig struct {
+	// StripBoilerplate removes navigation, header, footer and aside
+	// containers before extraction
+	StripBoilerplate bool
+	// MinScore is the quality score, 0 to 100, a page needs to be indexed
+	MinScore float64
+	// Weights are used by crawls that do not set their own, the zero value
+	// means DefaultQualityWeights
+	Weights QualityWeights
+}
+
+// validate checks the threshold and normalizes the weights
+func (q QualityConfig) validate() (QualityConfig, error) {
+	if q.MinScore < 0 || q.MinScore > 100 {
+		return QualityConfig{}, fmt.Errorf("min quality score must be between 0 and 100, got %v", q.MinScore)
+	}
+	if q.Weights == (QualityWeights{}) {
+		q.Weights = DefaultQualityWeights
+	}
+	weights, err := q.Weights.Normalize()
+	if err != nil {
+		return QualityConfig{}, err
+	}
+	q.Weights = weights
+	return q, nil
+}
+
+const boilerplateSelector = "nav, footer, aside, [role=navigation], [role=banner], [role=contentinfo]"
+
+// stripBoilerplate drops site chrome that pollutes the extracted text,
+// headers inside an article are kept as they usually hold its title
+func stripBoilerplate(body []byte) ([]byte, error) {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
-	// readabilityText, readabilityErr := w.ExtractWithReadability(body, pageURL)
+	doc.Find(boilerplateSelector).Remove()
+	doc.Find("header").Not("article header").Remove()
 
-	words := strings.Fields(content.TextContent)
-	wordCount := len(words)
-	htmlSize := len(content.HtmlNode)
-	textSize := len(content.TextContent)
-	lengthScoreVal := lengthScore(wordCount)
-
-	unique := make(map[string]struct{}, len(words))
-	for _, w := range words {
-		w = strings.ToLower(strings.Trim(w, ".,!?\"'():;[]{}"))
-		if w != "" {
-			unique[w] = struct{}{}
-		}
+	cleaned, err := doc.Html()
+	if err != nil {
+		return nil, err
 	}
-	vocabRichness := float64(len(unique)) / float64(len(words))
-	richnessScoreVal := richnessScore(vocabRichness)
+	return []byte(cleaned), nil
+}
 
-	re := regexp.MustCompile(`[.!?]+`)
-	sentences := re.Split(content.TextContent, -1)
-	sentenceCount := len(sentences)
-	if sentenceCount == 0 {
-		sentenceCount = 1 // avoid divide by zero
+// ExtractText extracts the main content of a page and evaluates its quality
+// with evaluator, TextMd is only filled when the verdict accepts the page
+func (w *Crawler) ExtractText(body []byte, pageURL string, evaluator QualityEvaluator) (*Content, error) {
+	if w.quality.StripBoilerplate {
+		cleaned, err := stripBoilerplate(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to strip boilerplate: %w", err)
+		}
+		body = cleaned
 	}
-	avgSentenceLength := float64(wordCount) / float64(sentenceCount)
-	sentenceScoreVal := sentenceScore(sentenceCount, avgSentenceLength)
 
-	finalScore := qualityScore(lengthScoreVal, richnessScoreVal, sentenceScoreVal)
-	if finalScore < 67 {
-		return nil, nil
+	content, err := w.ExtractWithTrafilatura(body, pageURL)
+	if err != nil {
+		return nil, err
 	}
+	// readabilityText, readabilityErr := w.ExtractWithReadability(body, pageURL)
 
+	verdict := evaluator.Evaluate(content)
+	content.Quality = &verdict
 	w.logger.Info("article_quality_metrics",
-		zap.String("url", pageURL),
-		zap.Int("word_count", wordCount),
-		zap.Float64("vocab_richness", vocabRichness),
-		zap.Int("sentence_count", sentenceCount),
-		zap.Float64("avg_sentence_length", avgSentenceLength),
-		zap.Int("html_size", htmlSize),
-		zap.Int("text_size", textSize),
-		zap.Float64("score", finalScore),
+		logURL(pageURL),
+		zap.Int("word_count", verdict.Metrics.WordCount),
+		zap.Float64("vocab_richness", verdict.Metrics.VocabRichness),
+		zap.Int("sentence_count", verdict.Metrics.SentenceCount),
+		zap.Float64("avg_sentence_length", verdict.Metrics.AvgSentenceLength),
+		zap.Int("html_size", verdict.Metrics.HTMLSize),
+		zap.Int("text_size", verdict.Metrics.TextSize),
+		zap.Float64("score", verdict.Metrics.Score),
+		zap.Bool("accepted", verdict.Accepted),
 	)
+	if !verdict.Accepted {
+		// rejected pages are not chunked, so skip the markdown conversion
+		return content, nil
+	}
 
 	textMd, err := htmltomarkdown.ConvertString(content.HtmlNode)
 	if err != nil {
@@ -193,42 +229,6 @@ func (w *Crawler) ExtractText(body []byte, pageURL string) (*Content, error) {
 	return content, nil
 }
 
-func lengthScore(wordCount int) float64 {
-	switch {
-	case wordCount < 200:
-		return 0.0
-	case wordCount > 10000:
-		return 0.7
-	default:
-		return 1.0 // ideal range
-	}
-}
-
-func richnessScore(vocabRichness float64) float64 {
-	switch {
-	case vocabRichness < 0.25:
-		return 0.0
-	case vocabRichness > 0.6:
-		return 0.8
-	default:
-		return 1.0
-	}
-}
-
-func sentenceScore(sentenceCount int, avgSentenceLength float64) float64 {
-	if sentenceCount < 5 {
-		return 0.0
-	}
-	if avgSentenceLength < 10 || avgSentenceLength > 30 {
-		return 0.7
-	}
-	return 1.0
-}
-
-func qualityScore(length, richness, sentence float64) float64 {
-	return (0.50*length + 0.30*richness + 0.20*sentence) * 100
-}
-
 func RenderNodeToString(n *html.Node) (string, error) {
 	var buf bytes.Buffer
 	if err := html.Render(&buf, n); err != nil {
@@ -0,0 +1,79 @@
+package crawler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"axora/pkg/httpclient"
+
+	"go.uber.org/zap"
+)
+
+func TestStripBoilerplate(t *testing.T) {
+	page := `<html><body>
+<header>Site header</header>
+<nav>Home | About</nav>
+<div role="navigation">Breadcrumbs</div>
+<div role="banner">Cookie banner</div>
+<article>
+  <header><h1>Article title</h1></header>
+  <p>Article body</p>
+  <aside>Related posts</aside>
+</article>
+<div role="contentinfo">Contact</div>
+<footer>Copyright</footer>
+</body></html>`
+
+	cleaned, err := stripBoilerplate([]byte(page))
+	if err != nil {
+		t.Fatalf("stripBoilerplate: %v", err)
+	}
+	html := string(cleaned)
+	for _, kept := range []string{"Article title", "Article body"} {
+		if !strings.Contains(html, kept) {
+			t.Errorf("%q was removed:\n%s", kept, html)
+		}
+	}
+	for _, dropped := range []string{"Site header", "Home | About", "Breadcrumbs", "Cookie banner", "Related posts", "Contact", "Copyright"} {
+		if strings.Contains(html, dropped) {
+			t.Errorf("%q was kept:\n%s", dropped, html)
+		}
+	}
+}
+
+func TestQualityConfigValidate(t *testing.T) {
+	got, err := QualityConfig{MinScore: 67}.validate()
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if got.MinScore != 67 || got.Weights != DefaultQualityWeights {
+		t.Fatalf("validate = %+v, want the threshold kept and the default weights", got)
+	}
+
+	got, err = QualityConfig{MinScore: 0, Weights: QualityWeights{Length: 1, Sentence: 1}}.validate()
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if got.Weights != (QualityWeights{Length: 0.5, Sentence: 0.5}) {
+		t.Fatalf("weights = %+v, want them normalized", got.Weights)
+	}
+
+	for _, invalid := range []QualityConfig{
+		{MinScore: -1},
+		{MinScore: 100.5},
+		{MinScore: 50, Weights: QualityWeights{Length: -1, Richness: 2}},
+	} {
+		if _, err := invalid.validate(); err == nil {
+			t.Errorf("validate(%+v) succeeded, want an error", invalid)
+		}
+	}
+}
+
+func TestNewCrawlerRejectsInvalidThreshold(t *testing.T) {
+	_, err := NewCrawler(httpclient.DefaultConfig(), zap.NewNop(), newFakeStore(), fakeChunker{},
+		nil, filepath.Join(t.TempDir(), "crawl.db"), 0, false, nil, QualityConfig{MinScore: 150})
+	if err == nil || !strings.Contains(err.Error(), "min quality score") {
+		t.Fatalf("NewCrawler error = %v, want the threshold rejected", err)
+	}
+}
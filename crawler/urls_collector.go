@@ -2,11 +2,12 @@ package crawler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/url"
+	"sync"
 	"time"
 
-	"github.com/chromedp/cdproto/cdp"
 	"github.com/chromedp/chromedp"
 	"go.uber.org/zap"
 )
@@ -22,13 +23,28 @@ type Browser struct {
 	logger           *zap.Logger
 	SupportedEngines []SearchEngine
 	ChromedpOptions  []chromedp.ExecAllocatorOption
-
-	maxPages    int
-	currentPage int
-	pageDelay   time.Duration
+	// ExecPath runs a specific Chrome binary instead of the one found in PATH
+	ExecPath string
+	// RemoteURL connects to an already running Chrome, e.g. a separate
+	// browser container, through its DevTools endpoint (ws://host:9222 or
+	// http://host:9222). The remote Chrome owns its flags, so ChromedpOptions,
+	// ExecPath and the proxy do not apply to it
+	RemoteURL      string
+	BlockDetection BlockDetection
+	// ResultFilter drops ads and other non-result links, links back to the
+	// engine's own domain are always dropped
+	ResultFilter HostFilter
+	// SearchBackend picks Chrome, HTTPSearch or Chrome with HTTPSearch as the
+	// fallback, see the SearchBackend constants
+	SearchBackend string
+	HTTPSearch    *HTTPSearch
+
+	maxPages      int
+	pageDelay     time.Duration
+	screenshotDir string // empty disables screenshots on errors
 }
 
-func NewBrowser(logger *zap.Logger, proxyURL string) *Browser {
+func NewBrowser(logger *zap.Logger, proxyURL string, screenshotDir string) *Browser {
 	return &Browser{
 		logger: logger,
 		SupportedEngines: []SearchEngine{
@@ -58,16 +74,93 @@ func NewBrowser(logger *zap.Logger, proxyURL string) *Browser {
 			chromedp.Flag("disable-extensions", ""),
 			chromedp.ProxyServer(proxyURL),
 		),
-		maxPages:    50,
-		currentPage: 0,
-		pageDelay:   time.Second * 2,
+		BlockDetection: DefaultBlockDetection,
+		SearchBackend:  SearchBackendChrome,
+		maxPages:       50,
+		pageDelay:      time.Second * 2,
+		screenshotDir:  screenshotDir,
+	}
+}
+
+func (b *Browser) CollectUrls(ctx context.Context, query string, collectedUrls chan string) error {
+	return b.withFallback(ctx, query, collectedUrls, func(ctx context.Context) error {
+		return b.collectFromEngine(ctx, query, b.SupportedEngines[1], collectedUrls)
+	})
+}
+
+// withFallback runs collect with Chrome, or HTTPSearch instead depending on
+// SearchBackend and on whether Chrome could be launched
+func (b *Browser) withFallback(ctx context.Context, query string, collectedUrls chan string,
+	collect func(ctx context.Context) error) error {
+	if b.HTTPSearch != nil && b.SearchBackend == SearchBackendHTTP {
+		return b.HTTPSearch.Collect(ctx, query, b.ResultFilter, collectedUrls)
+	}
+
+	err := collect(ctx)
+	if b.HTTPSearch != nil && b.SearchBackend == SearchBackendAuto && errors.Is(err, ErrBrowserUnavailable) {
+		b.logger.Warn("Chrome unavailable, falling back to HTTP search", zap.Error(err))
+		return b.HTTPSearch.Collect(ctx, query, b.ResultFilter, collectedUrls)
 	}
+	return err
 }
 
-func (b *Browser) CollectUrls(query string, collectedUrls chan string) error {
-	engine := b.SupportedEngines[1]
+// CollectUrlsParallel queries every engine concurrently, each in its own
+// browser, and sends every URL once to collectedUrls. A failing engine does
+// not stop the others, their errors are joined
+func (b *Browser) CollectUrlsParallel(ctx context.Context, query string, engines []SearchEngine,
+	collectedUrls chan string) error {
+	return b.withFallback(ctx, query, collectedUrls, func(ctx context.Context) error {
+		return b.collectParallel(ctx, query, engines, collectedUrls)
+	})
+}
+
+func (b *Browser) collectParallel(ctx context.Context, query string, engines []SearchEngine,
+	collectedUrls chan string) error {
+	merged := make(chan string, 100)
+	errs := make([]error, len(engines))
+	var wg sync.WaitGroup
+	for i, engine := range engines {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := b.collectFromEngine(ctx, query, engine, merged); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", engine.Name, err)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	unique := dedupeURLs(ctx, merged, collectedUrls)
+	b.logger.Info("Parallel collect Urls completed",
+		zap.Int("engines", len(engines)),
+		zap.Int("unique_urls", unique))
+	return errors.Join(errs...)
+}
 
-	taskCtx, cancel, err := b.setupBrowserContext(context.Background(), time.Hour*3)
+// dedupeURLs forwards every URL of merged once to collectedUrls until merged
+// is closed, and returns how many were unique. merged is drained even after
+// ctx is done so no sender blocks on it
+func dedupeURLs(ctx context.Context, merged <-chan string, collectedUrls chan string) int {
+	seen := make(map[string]struct{})
+	for href := range merged {
+		if _, ok := seen[href]; ok || ctx.Err() != nil {
+			continue
+		}
+		seen[href] = struct{}{}
+		select {
+		case collectedUrls <- href:
+		case <-ctx.Done():
+		}
+	}
+	return len(seen)
+}
+
+func (b *Browser) collectFromEngine(ctx context.Context, query string, engine SearchEngine,
+	collectedUrls chan string) error {
+	taskCtx, cancel, err := b.setupBrowserContext(ctx, time.Hour*3)
 	if err != nil {
 		return fmt.Errorf("failed to setup browser context: %w", err)
 	}
@@ -75,69 +168,48 @@ func (b *Browser) CollectUrls(query string, collectedUrls chan string) error {
 
 	searchURL := fmt.Sprintf(engine.URLTemplate, url.QueryEscape(query))
 	if err := b.navigateToPage(taskCtx, searchURL, engine.Name); err != nil {
+		b.screenshot(taskCtx, engine.Name, 1, "navigation")
 		return fmt.Errorf("failed to navigate to first page: %w", err)
 	}
 
-	for b.currentPage < b.maxPages {
-		b.currentPage++
-
-		b.logger.Info("Processing page",
-			zap.Int("current_page", b.currentPage),
-			zap.Int("max_pages", b.maxPages),
-			zap.String("engine", engine.Name))
-
-		if err := b.checkPageState(taskCtx); err != nil {
+	paginator := NewPaginator(b.logger, engine, b.maxPages, b.pageDelay)
+	pages, err := paginator.Run(taskCtx, func(ctx context.Context, page int) error {
+		if err := b.checkPageState(ctx, engine, page); err != nil {
 			b.logger.Warn("Page state check failed",
 				zap.Error(err),
-				zap.Int("page", b.currentPage))
+				zap.Int("page", page))
+			b.screenshot(ctx, engine.Name, page, "page-state")
 			return err
 		}
 
-		urlCount, err := b.extractLinksFromCurrentPage(taskCtx, engine, collectedUrls)
+		urlCount, err := b.extractLinksFromCurrentPage(ctx, engine, collectedUrls)
 		if err != nil {
 			b.logger.Error("Failed to extract links from page",
 				zap.Error(err),
-				zap.Int("page", b.currentPage))
+				zap.Int("page", page))
+			b.screenshot(ctx, engine.Name, page, "extraction")
 			return err
 		}
 
 		b.logger.Info("Collected URLs from page",
-			zap.Int("page", b.currentPage),
+			zap.Int("page", page),
 			zap.Int("urls_this_page", urlCount),
 		)
-
-		if b.currentPage >= b.maxPages {
-			b.logger.Info("Reached maximum pages", zap.Int("max_pages", b.maxPages))
-			break
-		}
-
-		hasNext, err := b.goToNextPage(taskCtx, engine)
-		if err != nil {
-			b.logger.Error("Failed to navigate to next page",
-				zap.Error(err),
-				zap.Int("current_page", b.currentPage))
-			return err
-		}
-
-		if !hasNext {
-			b.logger.Info("No more pages available", zap.Int("final_page", b.currentPage))
-			break
-		}
-
-		if b.pageDelay > 0 {
-			time.Sleep(b.pageDelay)
-		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
 	b.logger.Info("Collect Urls completed",
-		zap.Int("total_pages", b.currentPage),
+		zap.Int("total_pages", pages),
 		zap.String("engine", engine.Name))
 
 	return nil
 }
 
 func (b *Browser) setupBrowserContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc, error) {
-	allocCtx, allocCancel := chromedp.NewExecAllocator(ctx, b.ChromedpOptions...)
+	allocCtx, allocCancel := b.newAllocator(ctx)
 	taskCtx, taskCancel := chromedp.NewContext(allocCtx)
 
 	cancel := func() {
@@ -155,12 +227,30 @@ func (b *Browser) setupBrowserContext(ctx context.Context, timeout time.Duration
 		taskCtx = timeoutCtx
 	}
 
+	// Run without actions only launches the browser, so a missing Chrome is
+	// told apart from navigation failures
+	if err := chromedp.Run(taskCtx); err != nil {
+		cancel()
+		return nil, nil, fmt.Errorf("%w: %v", ErrBrowserUnavailable, err)
+	}
+
 	return taskCtx, cancel, nil
 }
 
+func (b *Browser) newAllocator(ctx context.Context) (context.Context, context.CancelFunc) {
+	if b.RemoteURL != "" {
+		return chromedp.NewRemoteAllocator(ctx, b.RemoteURL)
+	}
+	opts := b.ChromedpOptions
+	if b.ExecPath != "" {
+		opts = append(opts[:len(opts):len(opts)], chromedp.ExecPath(b.ExecPath))
+	}
+	return chromedp.NewExecAllocator(ctx, opts...)
+}
+
 func (b *Browser) navigateToPage(ctx context.Context, url, engineName string) error {
 	b.logger.Info("Navigating to page",
-		zap.String("url", url),
+		logURL(url),
 		zap.String("engine", engineName))
 
 	err := chromedp.Run(ctx,
@@ -171,14 +261,14 @@ func (b *Browser) navigateToPage(ctx context.Context, url, engineName string) er
 	if err != nil {
 		b.logger.Error("Navigation failed",
 			zap.Error(err),
-			zap.String("url", url))
+			logURL(url))
 		return fmt.Errorf("navigation failed: %w", err)
 	}
 
 	return nil
 }
 
-func (b *Browser) checkPageState(ctx context.Context) error {
+func (b *Browser) checkPageState(ctx context.Context, engine SearchEngine, page int) error {
 	var currentURL, title, readyState string
 
 	err := chromedp.Run(ctx,
@@ -192,12 +282,12 @@ func (b *Browser) checkPageState(ctx context.Context) error {
 	}
 
 	b.logger.Debug("Page state",
-		zap.String("url", currentURL),
+		logURL(currentURL),
 		zap.String("title", title),
 		zap.String("ready_state", readyState),
-		zap.Int("page", b.currentPage))
+		zap.Int("page", page))
 
-	return nil
+	return b.detectBlock(ctx, engine, currentURL, title)
 }
 
 // Optimized version that streams URLs directly to channel
@@ -232,8 +322,17 @@ func (b *Browser) extractLinksFromCurrentPage(ctx context.Context, engine Search
 		return 0, fmt.Errorf("failed to extract links: %w", err)
 	}
 
-	count := 0
+	var engineHost string
+	if u, err := url.Parse(engine.URLTemplate); err == nil {
+		engineHost = u.Hostname()
+	}
+
+	count, filtered := 0, 0
 	for _, href := range urls {
+		if isSameDomainLink(engineHost, href) || !b.ResultFilter.Allow(href) {
+			filtered++
+			continue
+		}
 		select {
 		case collectedUrls <- href:
 			count++
@@ -241,36 +340,11 @@ func (b *Browser) extractLinksFromCurrentPage(ctx context.Context, engine Search
 			return count, ctx.Err()
 		}
 	}
-
-	return count, nil
-}
-
-func (b *Browser) goToNextPage(ctx context.Context, engine SearchEngine) (bool, error) {
-	var nodes []*cdp.Node
-	err := chromedp.Run(ctx,
-		chromedp.Nodes(engine.NextPageSelector, &nodes, chromedp.ByQuery),
-	)
-	if err != nil || len(nodes) == 0 {
-		return false, nil
-	}
-
-	err = chromedp.Run(ctx,
-		chromedp.WaitVisible(engine.NextPageSelector, chromedp.ByQuery),
-	)
-	if err != nil {
-		return false, nil
+	if filtered > 0 {
+		b.logger.Debug("Filtered non-result links",
+			zap.String("engine", engine.Name),
+			zap.Int("filtered", filtered))
 	}
 
-	b.logger.Debug("Clicking next page button", zap.String("selector", engine.NextPageSelector))
-
-	err = chromedp.Run(ctx,
-		chromedp.Click(engine.NextPageSelector, chromedp.ByQuery),
-		chromedp.Sleep(2*time.Second),
-		chromedp.WaitReady("body"),
-	)
-	if err != nil {
-		return false, err
-	}
-
-	return true, nil
+	return count, nil
 }
@@ -0,0 +1,150 @@
+package crawler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/chromedp/chromedp"
+	"go.uber.org/zap"
+)
+
+func TestExtractLinksFiltersNonResults(t *testing.T) {
+	b, ctx := newTestTab(t)
+	b.ResultFilter = HostFilter{Exclude: []string{"ads.example"}}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html><body><div id="results">
+<a href="https://go.dev/doc">Go docs</a>
+<a href="https://go.dev/doc">Go docs again</a>
+<a href="https://www.search.example/settings">Settings</a>
+<a href="https://ads.example/click">Sponsored</a>
+<a href="http://insecure.example/">Plain http</a>
+<a href="https://empty.example/"></a>
+<a href="https://pkg.go.dev/">Packages</a>
+</div><a href="https://outside.example/">Outside results</a></body></html>`)
+	}))
+	defer srv.Close()
+	if err := chromedp.Run(ctx, chromedp.Navigate(srv.URL)); err != nil {
+		t.Fatalf("navigate: %v", err)
+	}
+
+	engine := SearchEngine{Name: "test", URLTemplate: "https://search.example/?q=%s", ResultSelector: "#results"}
+	ch := make(chan string, 10)
+	count, err := b.extractLinksFromCurrentPage(ctx, engine, ch)
+	if err != nil {
+		t.Fatalf("extractLinksFromCurrentPage: %v", err)
+	}
+	close(ch)
+
+	var got []string
+	for href := range ch {
+		got = append(got, href)
+	}
+	sort.Strings(got)
+	want := []string{"https://go.dev/doc", "https://pkg.go.dev/"}
+	if count != len(want) || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("extracted %d links %v, want %v", count, got, want)
+	}
+}
+
+func TestDedupeURLs(t *testing.T) {
+	merged := make(chan string)
+	var wg sync.WaitGroup
+	for _, engine := range [][]string{
+		{"https://a.example/", "https://b.example/", "https://a.example/"},
+		{"https://b.example/", "https://c.example/"},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for _, href := range engine {
+				merged <- href
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+
+	out := make(chan string, 10)
+	unique := dedupeURLs(context.Background(), merged, out)
+	close(out)
+
+	var got []string
+	for href := range out {
+		got = append(got, href)
+	}
+	sort.Strings(got)
+	want := []string{"https://a.example/", "https://b.example/", "https://c.example/"}
+	if unique != len(want) || fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("forwarded %d urls %v, want %v", unique, got, want)
+	}
+}
+
+func TestDedupeURLsDrainsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	merged := make(chan string)
+	go func() {
+		defer close(merged)
+		for i := 0; i < 100; i++ {
+			merged <- fmt.Sprintf("https://a.example/%d", i)
+		}
+	}()
+
+	// nobody reads out, so forwarding would block without the cancel
+	out := make(chan string)
+	if unique := dedupeURLs(ctx, merged, out); unique != 0 {
+		t.Fatalf("forwarded %d urls after cancel, want 0", unique)
+	}
+}
+
+func TestSetupBrowserContextConnectsToRemoteURL(t *testing.T) {
+	var mu sync.Mutex
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/json/version" {
+			// point at a debugger URL that refuses the websocket upgrade
+			fmt.Fprintf(w, `{"webSocketDebuggerUrl": "ws://%s/devtools/browser/test"}`, r.Host)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer srv.Close()
+
+	b := NewBrowser(zap.NewNop(), "", "")
+	b.RemoteURL = srv.URL
+	b.ExecPath = filepath.Join(t.TempDir(), "never-launched")
+	_, _, err := b.setupBrowserContext(context.Background(), 10*time.Second)
+	if !errors.Is(err, ErrBrowserUnavailable) {
+		t.Fatalf("setupBrowserContext = %v, want ErrBrowserUnavailable", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if fmt.Sprint(paths) != "[/json/version /devtools/browser/test]" {
+		t.Fatalf("remote endpoint received %v, want the version lookup then the websocket dial", paths)
+	}
+}
+
+func TestSetupBrowserContextUsesExecPath(t *testing.T) {
+	b := NewBrowser(zap.NewNop(), "", "")
+	b.ExecPath = filepath.Join(t.TempDir(), "no-such-chrome")
+	_, _, err := b.setupBrowserContext(context.Background(), 10*time.Second)
+	if !errors.Is(err, ErrBrowserUnavailable) || !strings.Contains(err.Error(), "no-such-chrome") {
+		t.Fatalf("setupBrowserContext = %v, want the missing binary reported as unavailable", err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -15,12 +16,10 @@ type MpnetBaseV2 struct {
 	HTTPClient *http.Client
 }
 
-func NewMpnetBaseV2(baseURL string) *MpnetBaseV2 {
+func NewMpnetBaseV2(baseURL string, httpClient *http.Client) *MpnetBaseV2 {
 	return &MpnetBaseV2{
-		BaseURL: baseURL,
-		HTTPClient: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		BaseURL:    baseURL,
+		HTTPClient: httpClient,
 	}
 }
 
@@ -33,24 +32,12 @@ func (c *MpnetBaseV2) GetEmbeddings(ctx context.Context, texts []string) ([][]fl
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/embed", bytes.NewBuffer(jsonData))
+	resp, err := c.post(ctx, jsonData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
-	}
-
-	req.Header.Set("Content-Type", "application/json")
-
-	resp, err := c.HTTPClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("service returned status %d: %s", resp.StatusCode, string(body))
-	}
-
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read response body: %w", err)
@@ -63,3 +50,74 @@ func (c *MpnetBaseV2) GetEmbeddings(ctx context.Context, texts []string) ([][]fl
 
 	return embeddings, nil
 }
+
+const (
+	maxRetries     = 3
+	maxRetryAfter  = 30 * time.Second
+	defaultBackoff = 500 * time.Millisecond
+)
+
+// post sends the embed request, retrying up to maxRetries times when the
+// server is busy. A 429 waits for Retry-After, a 5xx backs off exponentially
+func (c *MpnetBaseV2) post(ctx context.Context, jsonData []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+"/embed", bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := c.HTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		statusErr := fmt.Errorf("service returned status %d: %s", resp.StatusCode, string(body))
+
+		var wait time.Duration
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests:
+			wait = retryAfter(resp.Header.Get("Retry-After"))
+		case resp.StatusCode >= 500:
+			wait = defaultBackoff << attempt
+		default:
+			return nil, statusErr
+		}
+		if attempt == maxRetries {
+			return nil, statusErr
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as an HTTP date,
+// capped at maxRetryAfter and falling back to defaultBackoff
+func retryAfter(header string) time.Duration {
+	if header == "" {
+		return defaultBackoff
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(header); err == nil {
+		wait = time.Until(date)
+	} else {
+		return defaultBackoff
+	}
+	if wait < 0 {
+		return 0
+	}
+	return min(wait, maxRetryAfter)
+}
@@ -0,0 +1,198 @@
+package embedding
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingServer answers each request with the next response in order and
+// records when the requests arrived
+type recordingServer struct {
+	mu        sync.Mutex
+	responses []func(http.ResponseWriter)
+	arrivals  []time.Time
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	n := len(s.arrivals)
+	s.arrivals = append(s.arrivals, time.Now())
+	respond := s.responses[min(n, len(s.responses)-1)]
+	s.mu.Unlock()
+	respond(w)
+}
+
+func (s *recordingServer) requests() []time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]time.Time(nil), s.arrivals...)
+}
+
+func status(code int, header, value, body string) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		if header != "" {
+			w.Header().Set(header, value)
+		}
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func embeddings(vectors [][]float32) func(http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		_ = json.NewEncoder(w).Encode(vectors)
+	}
+}
+
+func newTestClient(t *testing.T, responses ...func(http.ResponseWriter)) (*MpnetBaseV2, *recordingServer) {
+	t.Helper()
+	rec := &recordingServer{responses: responses}
+	srv := httptest.NewServer(rec)
+	t.Cleanup(srv.Close)
+	return NewMpnetBaseV2(srv.URL, srv.Client()), rec
+}
+
+func TestGetEmbeddingsWaitsForRetryAfter(t *testing.T) {
+	client, rec := newTestClient(t,
+		status(http.StatusTooManyRequests, "Retry-After", "1", "slow down"),
+		embeddings([][]float32{{0.1, 0.2}}),
+	)
+
+	got, err := client.GetEmbeddings(context.Background(), []string{"text"})
+	if err != nil {
+		t.Fatalf("GetEmbeddings: %v", err)
+	}
+	if len(got) != 1 || len(got[0]) != 2 {
+		t.Fatalf("embeddings = %v, want one 2-d vector", got)
+	}
+
+	arrivals := rec.requests()
+	if len(arrivals) != 2 {
+		t.Fatalf("server got %d requests, want 2", len(arrivals))
+	}
+	if waited := arrivals[1].Sub(arrivals[0]); waited < time.Second {
+		t.Fatalf("retried after %s, want at least the 1s Retry-After", waited)
+	}
+}
+
+func TestGetEmbeddingsDoesNotRetryClientErrors(t *testing.T) {
+	client, rec := newTestClient(t,
+		status(http.StatusBadRequest, "", "", "inputs must not be empty"),
+		embeddings([][]float32{{0.1}}),
+	)
+
+	_, err := client.GetEmbeddings(context.Background(), []string{""})
+	if err == nil {
+		t.Fatal("GetEmbeddings succeeded on a 400")
+	}
+	if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "inputs must not be empty") {
+		t.Fatalf("error = %v, want the status and the response body", err)
+	}
+	if n := len(rec.requests()); n != 1 {
+		t.Fatalf("server got %d requests, want no retry", n)
+	}
+}
+
+func TestGetEmbeddingsStopsAtMaxRetries(t *testing.T) {
+	client, rec := newTestClient(t, status(http.StatusTooManyRequests, "Retry-After", "0", "busy"))
+
+	_, err := client.GetEmbeddings(context.Background(), []string{"text"})
+	if err == nil || !strings.Contains(err.Error(), "429") {
+		t.Fatalf("error = %v, want the last 429", err)
+	}
+	if n := len(rec.requests()); n != maxRetries+1 {
+		t.Fatalf("server got %d requests, want %d", n, maxRetries+1)
+	}
+}
+
+func TestGetEmbeddingsRetriesServerErrors(t *testing.T) {
+	client, rec := newTestClient(t,
+		status(http.StatusServiceUnavailable, "", "", "loading model"),
+		embeddings([][]float32{{0.5}}),
+	)
+
+	if _, err := client.GetEmbeddings(context.Background(), []string{"text"}); err != nil {
+		t.Fatalf("GetEmbeddings: %v", err)
+	}
+	if n := len(rec.requests()); n != 2 {
+		t.Fatalf("server got %d requests, want 2", n)
+	}
+}
+
+func TestGetEmbeddingsCancelledWhileWaiting(t *testing.T) {
+	client, rec := newTestClient(t, status(http.StatusTooManyRequests, "Retry-After", "30", "busy"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.GetEmbeddings(ctx, []string{"text"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error = %v, want the context deadline", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("returned after %s, the wait ignored the context", elapsed)
+	}
+	if n := len(rec.requests()); n != 1 {
+		t.Fatalf("server got %d requests, want 1", n)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", defaultBackoff},
+		{"2", 2 * time.Second},
+		{"0", 0},
+		{"-5", 0},
+		{"3600", maxRetryAfter},
+		{"soon", defaultBackoff},
+		{time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), 0},
+	}
+	for _, tt := range tests {
+		if got := retryAfter(tt.header); got != tt.want {
+			t.Errorf("retryAfter(%q) = %s, want %s", tt.header, got, tt.want)
+		}
+	}
+
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	if got := retryAfter(future); got <= 8*time.Second || got > 10*time.Second {
+		t.Errorf("retryAfter(%q) = %s, want about 10s", future, got)
+	}
+}
+
+func TestGetEmbeddingsCancelledInFlight(t *testing.T) {
+	arrived := make(chan struct{})
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the server only notices the client going away once the body is read
+		_, _ = io.Copy(io.Discard, r.Body)
+		close(arrived)
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer srv.Close()
+	defer close(release)
+	client := NewMpnetBaseV2(srv.URL, srv.Client())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-arrived
+		cancel()
+	}()
+	_, err := client.GetEmbeddings(ctx, []string{"text"})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,77 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Config describes an outbound HTTP client, zero durations disable the
+// corresponding timeout
+type Config struct {
+	ProxyURL              string
+	Timeout               time.Duration
+	ResponseHeaderTimeout time.Duration
+	IdleConnTimeout       time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	// TLSConfig overrides the TLS settings, nil uses Go's defaults
+	TLSConfig *tls.Config
+	// Logger, when set, logs every request made through the client, meant
+	// for debugging only
+	Logger *zap.Logger
+}
+
+// DefaultConfig is the transport tuning shared by every client, callers set
+// the proxy and the timeouts that fit their workload
+func DefaultConfig() Config {
+	return Config{
+		Timeout:               30 * time.Second,
+		ResponseHeaderTimeout: 20 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+	}
+}
+
+// New builds a client and its transport from cfg, the transport is returned
+// too for callers such as colly that manage it directly. An empty ProxyURL
+// connects directly. With a Logger the client wraps the transport, so changes
+// to the returned transport still apply
+func New(cfg Config) (*http.Client, *http.Transport, error) {
+	// the overall timeout includes waiting for the headers, a longer header
+	// timeout could never fire
+	if cfg.Timeout > 0 && cfg.ResponseHeaderTimeout > cfg.Timeout {
+		return nil, nil, fmt.Errorf("response header timeout %s exceeds the overall timeout %s",
+			cfg.ResponseHeaderTimeout, cfg.Timeout)
+	}
+	transport := &http.Transport{
+		IdleConnTimeout:       cfg.IdleConnTimeout,
+		MaxIdleConns:          cfg.MaxIdleConns,
+		MaxIdleConnsPerHost:   cfg.MaxIdleConnsPerHost,
+		ResponseHeaderTimeout: cfg.ResponseHeaderTimeout,
+		TLSClientConfig:       cfg.TLSConfig,
+	}
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid proxy url: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	var roundTripper http.RoundTripper = transport
+	if cfg.Logger != nil {
+		roundTripper = &loggingTransport{next: transport, logger: cfg.Logger}
+	}
+
+	client := &http.Client{
+		Transport: roundTripper,
+		Timeout:   cfg.Timeout,
+	}
+	return client, transport, nil
+}
@@ -0,0 +1,126 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDefaultConfigTimeoutsAreConsistent(t *testing.T) {
+	cfg := DefaultConfig()
+	if cfg.ResponseHeaderTimeout > cfg.Timeout {
+		t.Fatalf("response header timeout %s exceeds the overall timeout %s",
+			cfg.ResponseHeaderTimeout, cfg.Timeout)
+	}
+	if _, _, err := New(cfg); err != nil {
+		t.Fatalf("New(DefaultConfig()): %v", err)
+	}
+}
+
+func TestNewRejectsHeaderTimeoutAboveTimeout(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Timeout = time.Second
+	cfg.ResponseHeaderTimeout = time.Minute
+	if _, _, err := New(cfg); err == nil {
+		t.Fatal("New accepted a header timeout longer than the overall timeout")
+	}
+
+	// a zero overall timeout disables it, so any header timeout is fine
+	cfg.Timeout = 0
+	if _, _, err := New(cfg); err != nil {
+		t.Fatalf("New with no overall timeout: %v", err)
+	}
+}
+
+func TestNewAppliesTimeouts(t *testing.T) {
+	cfg := Config{
+		Timeout:               time.Minute,
+		ResponseHeaderTimeout: 10 * time.Second,
+		IdleConnTimeout:       5 * time.Second,
+		MaxIdleConns:          7,
+		MaxIdleConnsPerHost:   3,
+	}
+	client, transport, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if client.Timeout != cfg.Timeout {
+		t.Errorf("client timeout = %s, want %s", client.Timeout, cfg.Timeout)
+	}
+	if transport.ResponseHeaderTimeout != cfg.ResponseHeaderTimeout {
+		t.Errorf("response header timeout = %s, want %s", transport.ResponseHeaderTimeout, cfg.ResponseHeaderTimeout)
+	}
+	if transport.IdleConnTimeout != cfg.IdleConnTimeout {
+		t.Errorf("idle conn timeout = %s, want %s", transport.IdleConnTimeout, cfg.IdleConnTimeout)
+	}
+	if transport.MaxIdleConns != 7 || transport.MaxIdleConnsPerHost != 3 {
+		t.Errorf("idle conns = %d/%d, want 7/3", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.Proxy != nil {
+		t.Error("transport has a proxy without ProxyURL")
+	}
+	if client.Transport != transport {
+		t.Error("client does not use the returned transport without a Logger")
+	}
+}
+
+func TestNewResponseHeaderTimeoutFires(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	client, _, err := New(Config{Timeout: 5 * time.Second, ResponseHeaderTimeout: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	start := time.Now()
+	if _, err := client.Get(srv.URL); err == nil {
+		t.Fatal("request to a stalled server succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("request took %s, the header timeout did not apply", elapsed)
+	}
+}
+
+func TestNewRoutesThroughProxy(t *testing.T) {
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.String())
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer proxy.Close()
+
+	cfg := DefaultConfig()
+	cfg.ProxyURL = proxy.URL
+	client, _, err := New(cfg)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	resp, err := client.Get("http://origin.invalid/page?q=1")
+	if err != nil {
+		t.Fatalf("Get through proxy: %v", err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if resp.StatusCode != http.StatusNoContent || len(proxied) != 1 || proxied[0] != "http://origin.invalid/page?q=1" {
+		t.Fatalf("proxy saw %v with status %d, want the origin request", proxied, resp.StatusCode)
+	}
+}
+
+func TestNewRejectsInvalidProxy(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ProxyURL = "http://[::1"
+	if _, _, err := New(cfg); err == nil {
+		t.Fatal("New accepted an unparsable proxy url")
+	}
+}
@@ -0,0 +1,33 @@
+package httpclient
+
+import (
+	"net/http"
+	"time"
+
+	"axora/pkg/redact"
+
+	"go.uber.org/zap"
+)
+
+// loggingTransport logs every outbound request with its status and latency,
+// URLs are redacted so download keys and credentials stay out of the logs
+type loggingTransport struct {
+	next   http.RoundTripper
+	logger *zap.Logger
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	fields := []zap.Field{
+		zap.String("method", req.Method),
+		zap.String("url", redact.URL(req.URL.String())),
+		zap.Duration("latency", time.Since(start)),
+	}
+	if err != nil {
+		t.logger.Info("outbound request failed", append(fields, zap.Error(err))...)
+		return nil, err
+	}
+	t.logger.Info("outbound request", append(fields, zap.Int("status", resp.StatusCode))...)
+	return resp, nil
+}
@@ -0,0 +1,81 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func newLoggedClient(t *testing.T) (*http.Client, *observer.ObservedLogs) {
+	t.Helper()
+	core, logs := observer.New(zapcore.InfoLevel)
+	client, _, err := New(Config{Timeout: 5 * time.Second, Logger: zap.New(core)})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return client, logs
+}
+
+func TestLoggingTransportLogsRedactedRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+	client, logs := newLoggedClient(t)
+
+	resp, err := client.Get(srv.URL + "/get.php?md5=abc&key=s3cret&api_key=hunter2")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	resp.Body.Close()
+
+	entries := logs.FilterMessage("outbound request").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d requests, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["method"] != http.MethodGet {
+		t.Errorf("method = %v, want GET", fields["method"])
+	}
+	if fields["status"] != int64(http.StatusCreated) {
+		t.Errorf("status = %v, want 201", fields["status"])
+	}
+	if _, ok := fields["latency"]; !ok {
+		t.Error("latency was not logged")
+	}
+	url, _ := fields["url"].(string)
+	if strings.Contains(url, "s3cret") || strings.Contains(url, "hunter2") {
+		t.Fatalf("url %q leaks a secret", url)
+	}
+	if !strings.Contains(url, "md5=abc") || strings.Count(url, "REDACTED") != 2 {
+		t.Fatalf("url = %q, want md5 kept and key and api_key redacted", url)
+	}
+}
+
+func TestLoggingTransportLogsFailedRequest(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	client, logs := newLoggedClient(t)
+
+	if _, err := client.Get(srv.URL + "/?key=s3cret"); err == nil {
+		t.Fatal("request to a closed server succeeded")
+	}
+
+	entries := logs.FilterMessage("outbound request failed").All()
+	if len(entries) != 1 {
+		t.Fatalf("logged %d failures, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if _, ok := fields["error"]; !ok {
+		t.Error("the error was not logged")
+	}
+	if url, _ := fields["url"].(string); strings.Contains(url, "s3cret") {
+		t.Fatalf("url %q leaks the key", url)
+	}
+}
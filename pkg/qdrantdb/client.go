@@ -1,14 +1,79 @@
 package qdrantdb
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/qdrant/go-client/qdrant"
 )
 
 type CrawlClient struct {
-	Client *qdrant.Client
+	Client     *qdrant.Client
+	vectorSize int
+	distance   qdrant.Distance
+	payload    PayloadConfig
+}
+
+const (
+	// ContentFull stores the whole chunk text in the payload
+	ContentFull = "full"
+	// ContentTruncate stores at most MaxContentLen runes of the chunk text
+	ContentTruncate = "truncate"
+	// ContentHash stores only the sha256 of the chunk text, for setups where
+	// the text lives elsewhere
+	ContentHash = "hash"
+)
+
+// PayloadConfig controls how much of a chunk's text is kept in qdrant
+type PayloadConfig struct {
+	ContentMode   string
+	MaxContentLen int
+}
+
+func (p PayloadConfig) validate() error {
+	switch p.ContentMode {
+	case ContentFull, ContentHash:
+	case ContentTruncate:
+		if p.MaxContentLen <= 0 {
+			return fmt.Errorf("max content length must be positive in truncate mode, got %d", p.MaxContentLen)
+		}
+	default:
+		return fmt.Errorf("unsupported content mode %q, expected full, truncate or hash", p.ContentMode)
+	}
+	return nil
+}
+
+var distances = map[string]qdrant.Distance{
+	"cosine":    qdrant.Distance_Cosine,
+	"dot":       qdrant.Distance_Dot,
+	"euclid":    qdrant.Distance_Euclid,
+	"manhattan": qdrant.Distance_Manhattan,
 }
 
-func NewClient(host string, port int) (*CrawlClient, error) {
+// ParseDistance maps cosine, dot, euclid or manhattan to the qdrant metric
+func ParseDistance(name string) (qdrant.Distance, error) {
+	distance, ok := distances[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return 0, fmt.Errorf("unsupported distance %q, expected cosine, dot, euclid or manhattan", name)
+	}
+	return distance, nil
+}
+
+// NewClient connects to qdrant, vectorSize must match the output dimension of
+// the embedding model (768 for mpnet-base-v2, 384 for all-MiniLM-L6-v2) and
+// distance should be the metric the model was trained for
+func NewClient(host string, port int, vectorSize int, distance string,
+	payload PayloadConfig) (*CrawlClient, error) {
+	if vectorSize <= 0 {
+		return nil, fmt.Errorf("vector size must be positive, got %d", vectorSize)
+	}
+	metric, err := ParseDistance(distance)
+	if err != nil {
+		return nil, err
+	}
+	if err := payload.validate(); err != nil {
+		return nil, err
+	}
 	client, err := qdrant.NewClient(&qdrant.Config{
 		Host: host,
 		Port: port, // gRPC port
@@ -16,5 +81,10 @@ func NewClient(host string, port int) (*CrawlClient, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &CrawlClient{Client: client}, err
+	return &CrawlClient{
+		Client:     client,
+		vectorSize: vectorSize,
+		distance:   metric,
+		payload:    payload,
+	}, err
 }
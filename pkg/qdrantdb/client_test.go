@@ -0,0 +1,123 @@
+package qdrantdb
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"axora/crawler"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+func TestParseDistance(t *testing.T) {
+	tests := map[string]qdrant.Distance{
+		"cosine":    qdrant.Distance_Cosine,
+		"dot":       qdrant.Distance_Dot,
+		" Euclid ":  qdrant.Distance_Euclid,
+		"MANHATTAN": qdrant.Distance_Manhattan,
+		"":          0,
+		"l2":        0,
+		"euclidean": 0,
+	}
+	for name, want := range tests {
+		got, err := ParseDistance(name)
+		if want == 0 {
+			if err == nil {
+				t.Errorf("ParseDistance(%q) = %v, want an error", name, got)
+			}
+			continue
+		}
+		if err != nil || got != want {
+			t.Errorf("ParseDistance(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+}
+
+func TestNewClientRejectsInvalidVectorSettings(t *testing.T) {
+	if _, err := NewClient("localhost", 6334, 0, "cosine", fullPayload); err == nil {
+		t.Error("NewClient accepted a zero vector size")
+	}
+	if _, err := NewClient("localhost", 6334, testVectorSize, "l2", fullPayload); err == nil {
+		t.Error("NewClient accepted an unknown distance")
+	}
+}
+
+func TestCreateCollectionUsesConfiguredDistance(t *testing.T) {
+	client, fake := newTestClient(t, testVectorSize, "euclid", fullPayload)
+	if err := client.CreateCollection(context.Background()); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	if got := fake.collection.GetDistance(); got != qdrant.Distance_Euclid {
+		t.Fatalf("collection created with %v distance, want Euclid", got)
+	}
+}
+
+func TestCreateCollectionRejectsDistanceMismatch(t *testing.T) {
+	client, fake := newTestClient(t, testVectorSize, "euclid", fullPayload)
+	fake.collection = &qdrant.VectorParams{Size: testVectorSize, Distance: qdrant.Distance_Cosine}
+
+	err := client.CreateCollection(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "distance") {
+		t.Fatalf("CreateCollection error = %v, want a distance mismatch", err)
+	}
+}
+
+func TestEuclidSearchRanksByAscendingDistance(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newTestClient(t, testVectorSize, "euclid", fullPayload)
+	if err := client.CreateCollection(ctx); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc("https://a.example/x", "far", 4, 0, 0, 0),
+		testDoc("https://a.example/y", "exact", 1, 0, 0, 0),
+		testDoc("https://a.example/z", "close", 1, 1, 0, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+
+	hits, err := client.Search(ctx, []float32{1, 0, 0, 0}, 10)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 3 || hits[0].Content != "exact" || hits[1].Content != "close" || hits[2].Content != "far" {
+		t.Fatalf("hits = %+v, want exact, close, far", hits)
+	}
+	if hits[0].Score != 0 || hits[1].Score != 1 {
+		t.Errorf("scores = %v, %v, want the distances 0 and 1", hits[0].Score, hits[1].Score)
+	}
+
+	// with a distance metric the threshold is an upper bound
+	hits, err = client.Search(ctx, []float32{1, 0, 0, 0}, 10, crawler.WithScoreThreshold(1.5))
+	if err != nil {
+		t.Fatalf("Search with threshold: %v", err)
+	}
+	if len(hits) != 2 || hits[0].Content != "exact" || hits[1].Content != "close" {
+		t.Fatalf("hits = %+v, want the far chunk dropped", hits)
+	}
+}
+
+func TestPayloadConfigValidate(t *testing.T) {
+	tests := []struct {
+		payload PayloadConfig
+		valid   bool
+	}{
+		{PayloadConfig{ContentMode: ContentFull}, true},
+		{PayloadConfig{ContentMode: ContentHash}, true},
+		{PayloadConfig{ContentMode: ContentTruncate, MaxContentLen: 100}, true},
+		{PayloadConfig{ContentMode: ContentTruncate}, false},
+		{PayloadConfig{ContentMode: ContentTruncate, MaxContentLen: -1}, false},
+		{PayloadConfig{}, false},
+		{PayloadConfig{ContentMode: "summary"}, false},
+	}
+	for _, tt := range tests {
+		if err := tt.payload.validate(); (err == nil) != tt.valid {
+			t.Errorf("validate(%+v) = %v, want valid %v", tt.payload, err, tt.valid)
+		}
+	}
+	if _, err := NewClient("localhost", 6334, testVectorSize, "cosine", PayloadConfig{ContentMode: "summary"}); err == nil {
+		t.Error("NewClient accepted an unknown content mode")
+	}
+}
@@ -4,7 +4,10 @@ import (
 	"axora/crawler"
 	"context"
 	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/qdrant/go-client/qdrant"
@@ -14,35 +17,71 @@ const (
 	CrawlCollectionName = "crawl_collection"
 )
 
-func (c *CrawlClient) CreateCrawlCollection(ctx context.Context) error {
+var _ crawler.VectorStore = (*CrawlClient)(nil)
+
+func (c *CrawlClient) CreateCollection(ctx context.Context) error {
 	exists, err := c.Client.CollectionExists(ctx, CrawlCollectionName)
 	if err != nil {
 		return err
 	}
-	if exists {
-		return nil
+	if !exists {
+		err = c.Client.CreateCollection(ctx, &qdrant.CreateCollection{
+			CollectionName: CrawlCollectionName,
+			VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+				Size:     uint64(c.vectorSize),
+				Distance: c.distance,
+			}),
+		})
+		if err != nil {
+			return fmt.Errorf("err create crawl collection: %w", err)
+		}
+	} else if err := c.checkVectorParams(ctx); err != nil {
+		return err
 	}
-	err = c.Client.CreateCollection(ctx, &qdrant.CreateCollection{
-		CollectionName: CrawlCollectionName,
-		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
-			Size:     768,
-			Distance: qdrant.Distance_Cosine,
-		}),
-	})
 
+	// creating an index that already exists is a no-op, so this also
+	// backfills indexes on collections created before they were introduced
+	for _, field := range []string{"host", "url"} {
+		_, err = c.Client.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: CrawlCollectionName,
+			FieldName:      field,
+			FieldType:      qdrant.FieldType_FieldTypeKeyword.Enum(),
+		})
+		if err != nil {
+			return fmt.Errorf("err create %s index: %w", field, err)
+		}
+	}
+	return nil
+}
+
+// checkVectorParams fails when an existing collection was created with another
+// vector size or metric. Every insert would be rejected on a size mismatch,
+// and scores and thresholds would silently mean something else on a metric one
+func (c *CrawlClient) checkVectorParams(ctx context.Context) error {
+	info, err := c.Client.GetCollectionInfo(ctx, CrawlCollectionName)
 	if err != nil {
-		return fmt.Errorf("err create content_hash index: %w", err)
+		return fmt.Errorf("err get crawl collection info: %w", err)
+	}
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return nil
+	}
+	if params.GetSize() != uint64(c.vectorSize) {
+		return fmt.Errorf("crawl collection has vector size %d, configured %d",
+			params.GetSize(), c.vectorSize)
+	}
+	if params.GetDistance() != c.distance {
+		return fmt.Errorf("crawl collection uses %s distance, configured %s",
+			params.GetDistance(), c.distance)
 	}
 	return nil
 }
 
 func (c *CrawlClient) InsertOne(ctx context.Context, doc *crawler.CrawlVectorDoc) error {
-	// Using content hash as PK, bcs QdrantDB only accepts UUID and num as PK
-	// so we need to convert the hash to UUID
-	hash := sha256.Sum256([]byte(doc.Content))
-	hashBytes := hash[:16]
-	namespace := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
-	id := uuid.NewSHA1(namespace, hashBytes).String()
+	if err := c.validateVector(doc.ContentEmbedding); err != nil {
+		return err
+	}
+	id := pointID(doc.Content)
 
 	resp, err := c.Client.Get(ctx, &qdrant.GetPoints{
 		CollectionName: CrawlCollectionName,
@@ -55,20 +94,354 @@ func (c *CrawlClient) InsertOne(ctx context.Context, doc *crawler.CrawlVectorDoc
 		return nil
 	}
 
+	_, err = c.Client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: CrawlCollectionName,
+		Points:         []*qdrant.PointStruct{c.newPoint(id, doc)},
+	})
+
+	return err
+}
+
+// InsertBatch inserts docs with one Get and one Upsert round trip, skipping
+// chunks already stored and duplicates within the batch
+func (c *CrawlClient) InsertBatch(ctx context.Context, docs []*crawler.CrawlVectorDoc) error {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(docs))
+	pointIDs := make([]*qdrant.PointId, 0, len(docs))
+	byID := make(map[string]*crawler.CrawlVectorDoc, len(docs))
+	for _, doc := range docs {
+		if err := c.validateVector(doc.ContentEmbedding); err != nil {
+			return fmt.Errorf("url %s: %w", doc.URL, err)
+		}
+		id := pointID(doc.Content)
+		if _, dup := byID[id]; dup {
+			continue
+		}
+		byID[id] = doc
+		ids = append(ids, id)
+		pointIDs = append(pointIDs, qdrant.NewID(id))
+	}
+
+	existing, err := c.Client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: CrawlCollectionName,
+		Ids:            pointIDs,
+	})
+	if err != nil {
+		return err
+	}
+	stored := make(map[string]struct{}, len(existing))
+	for _, p := range existing {
+		stored[p.GetId().GetUuid()] = struct{}{}
+	}
+
+	points := make([]*qdrant.PointStruct, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := stored[id]; ok {
+			continue
+		}
+		points = append(points, c.newPoint(id, byID[id]))
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	_, err = c.Client.Upsert(ctx, &qdrant.UpsertPoints{
+		CollectionName: CrawlCollectionName,
+		Points:         points,
+	})
+	return err
+}
+
+func (c *CrawlClient) validateVector(vector []float32) error {
+	if len(vector) != c.vectorSize {
+		return fmt.Errorf("vector dimension mismatch: collection expects %d, got %d",
+			c.vectorSize, len(vector))
+	}
+	return nil
+}
+
+// pointID uses the content hash as PK, bcs QdrantDB only accepts UUID and num
+// as PK so we need to convert the hash to UUID
+func pointID(content string) string {
+	hash := sha256.Sum256([]byte(content))
+	hashBytes := hash[:16]
+	namespace := uuid.MustParse("123e4567-e89b-12d3-a456-426614174000")
+	return uuid.NewSHA1(namespace, hashBytes).String()
+}
+
+func (c *CrawlClient) newPoint(id string, doc *crawler.CrawlVectorDoc) *qdrant.PointStruct {
 	md := map[string]any{
-		"url":          doc.URL,
-		"page_content": doc.Content,
+		"url":           doc.URL,
+		"host":          urlHost(doc.URL),
+		"title":         doc.Title,
+		"sitename":      doc.SiteName,
+		"crawled_at":    doc.CrawledAt.UTC().Format(time.RFC3339),
+		"quality_score": doc.QualityScore,
 	}
-	point := &qdrant.PointStruct{
+	switch c.payload.ContentMode {
+	case ContentHash:
+		hash := sha256.Sum256([]byte(doc.Content))
+		md["content_hash"] = hex.EncodeToString(hash[:])
+	case ContentTruncate:
+		content := []rune(doc.Content)
+		if len(content) > c.payload.MaxContentLen {
+			md["page_content"] = string(content[:c.payload.MaxContentLen])
+			md["content_truncated"] = true
+		} else {
+			md["page_content"] = doc.Content
+		}
+	default:
+		md["page_content"] = doc.Content
+	}
+	return &qdrant.PointStruct{
 		Id:      qdrant.NewID(id),
 		Vectors: qdrant.NewVectorsDense(doc.ContentEmbedding),
 		Payload: qdrant.NewValueMap(md),
 	}
+}
+
+// DeleteByURL removes every chunk stored for url
+func (c *CrawlClient) DeleteByURL(ctx context.Context, url string) error {
+	return c.deleteByURL(ctx, url, nil)
+}
+
+// ReplaceByURL stores docs as the chunks of url, then deletes the chunks of
+// its previous version. Inserting first means a failed or cancelled re-index
+// leaves the previous chunks in place instead of none
+func (c *CrawlClient) ReplaceByURL(ctx context.Context, url string, docs []*crawler.CrawlVectorDoc) error {
+	if err := c.InsertBatch(ctx, docs); err != nil {
+		return err
+	}
+	keep := make([]*qdrant.PointId, 0, len(docs))
+	for _, doc := range docs {
+		keep = append(keep, qdrant.NewID(pointID(doc.Content)))
+	}
+	return c.deleteByURL(ctx, url, keep)
+}
 
-	_, err = c.Client.Upsert(ctx, &qdrant.UpsertPoints{
+// deleteByURL removes the chunks stored for url except those in keep
+func (c *CrawlClient) deleteByURL(ctx context.Context, url string, keep []*qdrant.PointId) error {
+	filter := &qdrant.Filter{
+		Must: []*qdrant.Condition{qdrant.NewMatch("url", url)},
+	}
+	if len(keep) > 0 {
+		filter.MustNot = []*qdrant.Condition{qdrant.NewHasID(keep...)}
+	}
+	_, err := c.Client.Delete(ctx, &qdrant.DeletePoints{
 		CollectionName: CrawlCollectionName,
-		Points:         []*qdrant.PointStruct{point},
+		Wait:           qdrant.PtrOf(true),
+		Points:         qdrant.NewPointsSelectorFilter(filter),
 	})
+	if err != nil {
+		return fmt.Errorf("err delete points of %s: %w", url, err)
+	}
+	return nil
+}
 
-	return err
+// Search returns the topK nearest chunks ranked by the collection metric,
+// qdrant orders distance metrics ascending and similarities descending
+func (c *CrawlClient) Search(ctx context.Context, vector []float32, topK int,
+	opts ...crawler.SearchOption) ([]crawler.SearchHit, error) {
+	if topK <= 0 {
+		return nil, fmt.Errorf("topK must be positive, got %d", topK)
+	}
+	if err := c.validateVector(vector); err != nil {
+		return nil, err
+	}
+	var options crawler.SearchOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	var filter *qdrant.Filter
+	if len(options.Hosts) > 0 || len(options.ExcludeIDs) > 0 {
+		filter = &qdrant.Filter{}
+	}
+	if len(options.Hosts) > 0 {
+		filter.Must = []*qdrant.Condition{qdrant.NewMatchKeywords("host", options.Hosts...)}
+	}
+	if len(options.ExcludeIDs) > 0 {
+		ids := make([]*qdrant.PointId, 0, len(options.ExcludeIDs))
+		for _, id := range options.ExcludeIDs {
+			ids = append(ids, qdrant.NewID(id))
+		}
+		filter.MustNot = []*qdrant.Condition{qdrant.NewHasID(ids...)}
+	}
+
+	points, err := c.Client.Query(ctx, &qdrant.QueryPoints{
+		CollectionName: CrawlCollectionName,
+		Query:          qdrant.NewQuery(vector...),
+		Filter:         filter,
+		Limit:          qdrant.PtrOf(uint64(topK)),
+		ScoreThreshold: options.ScoreThreshold,
+		WithPayload:    qdrant.NewWithPayload(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("err query crawl collection: %w", err)
+	}
+
+	hits := make([]crawler.SearchHit, 0, len(points))
+	for _, p := range points {
+		doc := docFromPayload(p.GetPayload())
+		hits = append(hits, crawler.SearchHit{
+			ID:           p.GetId().GetUuid(),
+			URL:          doc.URL,
+			Title:        doc.Title,
+			SiteName:     doc.SiteName,
+			Content:      doc.Content,
+			CrawledAt:    doc.CrawledAt,
+			QualityScore: doc.QualityScore,
+			Score:        p.GetScore(),
+		})
+	}
+	return hits, nil
+}
+
+// GetPoint returns the payload and vector stored under id, or
+// crawler.ErrPointNotFound when there is none. IDs that are not UUIDs can
+// never be stored, so they are reported as not found too
+func (c *CrawlClient) GetPoint(ctx context.Context, id string) (*crawler.StoredPoint, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, fmt.Errorf("%w: invalid id %q", crawler.ErrPointNotFound, id)
+	}
+
+	points, err := c.Client.Get(ctx, &qdrant.GetPoints{
+		CollectionName: CrawlCollectionName,
+		Ids:            []*qdrant.PointId{qdrant.NewID(id)},
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("err get point %s: %w", id, err)
+	}
+	if len(points) == 0 {
+		return nil, crawler.ErrPointNotFound
+	}
+
+	doc := docFromPayload(points[0].GetPayload())
+	doc.ContentEmbedding = denseVector(points[0].GetVectors().GetVector())
+	return &crawler.StoredPoint{ID: id, CrawlVectorDoc: doc}, nil
+}
+
+// denseVector reads the dense oneof current qdrant servers fill, falling back
+// to the deprecated data field older servers use
+func denseVector(v *qdrant.VectorOutput) []float32 {
+	if dense := v.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return v.GetData()
+}
+
+// docFromPayload is the inverse of newPoint, without the vector. Content is
+// empty or partial when the point was stored in hash or truncate mode
+func docFromPayload(payload map[string]*qdrant.Value) crawler.CrawlVectorDoc {
+	// points stored before crawled_at existed parse to the zero time
+	crawledAt, _ := time.Parse(time.RFC3339, payload["crawled_at"].GetStringValue())
+	return crawler.CrawlVectorDoc{
+		URL:          payload["url"].GetStringValue(),
+		Title:        payload["title"].GetStringValue(),
+		SiteName:     payload["sitename"].GetStringValue(),
+		Content:      payload["page_content"].GetStringValue(),
+		CrawledAt:    crawledAt,
+		QualityScore: payload["quality_score"].GetDoubleValue(),
+	}
+}
+
+func urlHost(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// Scroll streams every stored chunk, vector included, fetching batchSize
+// points per page. Errors fetching the first page are returned at once. The
+// channel is closed after the last point, when ctx is cancelled or when a
+// later page fails, so callers that must know every point was visited, such
+// as backups, should use ScrollFunc. Cancel ctx when abandoning the channel
+// before it is closed, so the paging goroutine exits
+func (c *CrawlClient) Scroll(ctx context.Context, batchSize int) (<-chan *crawler.CrawlVectorDoc, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	first, err := c.scrollPage(ctx, nil, batchSize)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make(chan *crawler.CrawlVectorDoc, batchSize)
+	go func() {
+		defer close(docs)
+		_ = c.scrollFrom(ctx, first, batchSize, func(doc *crawler.CrawlVectorDoc) error {
+			select {
+			case docs <- doc:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	return docs, nil
+}
+
+// ScrollFunc calls fn with every stored chunk, vector included, fetching
+// batchSize points per page. It stops at the first error, whether a page
+// fails, ctx is cancelled or fn returns one, and returns it, so a nil error
+// means every point was visited exactly once
+func (c *CrawlClient) ScrollFunc(ctx context.Context, batchSize int,
+	fn func(*crawler.CrawlVectorDoc) error) error {
+	if batchSize <= 0 {
+		return fmt.Errorf("batch size must be positive, got %d", batchSize)
+	}
+	first, err := c.scrollPage(ctx, nil, batchSize)
+	if err != nil {
+		return err
+	}
+	return c.scrollFrom(ctx, first, batchSize, fn)
+}
+
+// scrollFrom calls fn with the points of page and of every following page
+func (c *CrawlClient) scrollFrom(ctx context.Context, page *qdrant.ScrollResponse, batchSize int,
+	fn func(*crawler.CrawlVectorDoc) error) error {
+	for {
+		for _, p := range page.GetResult() {
+			doc := docFromPayload(p.GetPayload())
+			doc.ContentEmbedding = denseVector(p.GetVectors().GetVector())
+			if err := fn(&doc); err != nil {
+				return err
+			}
+		}
+
+		offset := page.GetNextPageOffset()
+		if offset == nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		next, err := c.scrollPage(ctx, offset, batchSize)
+		if err != nil {
+			return err
+		}
+		page = next
+	}
+}
+
+func (c *CrawlClient) scrollPage(ctx context.Context, offset *qdrant.PointId,
+	batchSize int) (*qdrant.ScrollResponse, error) {
+	resp, err := c.Client.GetPointsClient().Scroll(ctx, &qdrant.ScrollPoints{
+		CollectionName: CrawlCollectionName,
+		Offset:         offset,
+		Limit:          qdrant.PtrOf(uint32(batchSize)),
+		WithPayload:    qdrant.NewWithPayload(true),
+		WithVectors:    qdrant.NewWithVectors(true),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("err scroll crawl collection: %w", err)
+	}
+	return resp, nil
 }
@@ -0,0 +1,590 @@
+package qdrantdb
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"axora/crawler"
+
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const testVectorSize = 4
+
+var fullPayload = PayloadConfig{ContentMode: ContentFull}
+
+func testDoc(url, content string, vector ...float32) *crawler.CrawlVectorDoc {
+	return &crawler.CrawlVectorDoc{
+		URL:              url,
+		Title:            "title of " + content,
+		SiteName:         "site",
+		Content:          content,
+		ContentEmbedding: vector,
+		CrawledAt:        time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		QualityScore:     80,
+	}
+}
+
+func TestCreateCollectionRejectsVectorSizeMismatch(t *testing.T) {
+	client, fake := newTestClient(t, testVectorSize, "cosine", fullPayload)
+	fake.collection = &qdrant.VectorParams{Size: 8, Distance: qdrant.Distance_Cosine}
+
+	err := client.CreateCollection(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "vector size 8") {
+		t.Fatalf("CreateCollection error = %v, want a vector size mismatch", err)
+	}
+}
+
+func TestCreateCollectionAcceptsMatchingCollection(t *testing.T) {
+	client, fake := newTestClient(t, testVectorSize, "cosine", fullPayload)
+	fake.collection = &qdrant.VectorParams{Size: testVectorSize, Distance: qdrant.Distance_Cosine}
+
+	if err := client.CreateCollection(context.Background()); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+}
+
+func TestInsertAndSearchRejectWrongDimension(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newTestClient(t, testVectorSize, "cosine", fullPayload)
+	if err := client.CreateCollection(ctx); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+
+	docs := []*crawler.CrawlVectorDoc{
+		testDoc("https://a.example/1", "ok", 1, 0, 0, 0),
+		testDoc("https://a.example/1", "short", 1, 0, 0),
+	}
+	if err := client.InsertBatch(ctx, docs); err == nil || !strings.Contains(err.Error(), "dimension mismatch") {
+		t.Fatalf("InsertBatch error = %v, want a dimension mismatch", err)
+	}
+	if ids := fake.ids(); len(ids) != 0 {
+		t.Fatalf("a rejected batch stored %d points", len(ids))
+	}
+	if err := client.InsertOne(ctx, docs[1]); err == nil {
+		t.Fatal("InsertOne accepted a short vector")
+	}
+	if _, err := client.Search(ctx, []float32{1, 0}, 5); err == nil {
+		t.Fatal("Search accepted a short vector")
+	}
+}
+
+// urlPoints returns the IDs of the points stored for url
+func (f *fakeQdrant) urlPoints(url string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var ids []string
+	for _, id := range f.sortedIDs() {
+		if f.points[id].GetPayload()["url"].GetStringValue() == url {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func newCollection(t *testing.T) (*CrawlClient, *fakeQdrant) {
+	t.Helper()
+	client, fake := newTestClient(t, testVectorSize, "cosine", fullPayload)
+	if err := client.CreateCollection(context.Background()); err != nil {
+		t.Fatalf("CreateCollection: %v", err)
+	}
+	return client, fake
+}
+
+func TestDeleteByURLRemovesOnlyThatURL(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newCollection(t)
+	page, other := "https://a.example/page", "https://a.example/other"
+
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc(page, "first chunk", 1, 0, 0, 0),
+		testDoc(page, "second chunk", 0, 1, 0, 0),
+		testDoc(other, "other chunk", 0, 0, 1, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+	if n := len(fake.urlPoints(page)); n != 2 {
+		t.Fatalf("%d points stored for the page, want 2", n)
+	}
+
+	if err := client.DeleteByURL(ctx, page); err != nil {
+		t.Fatalf("DeleteByURL: %v", err)
+	}
+	if n := len(fake.urlPoints(page)); n != 0 {
+		t.Fatalf("%d points remain for the page, want 0", n)
+	}
+	if n := len(fake.urlPoints(other)); n != 1 {
+		t.Fatalf("%d points remain for the other url, want 1", n)
+	}
+}
+
+func TestReplaceByURLKeepsOnlyTheNewVersion(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newCollection(t)
+	page := "https://a.example/page"
+
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc(page, "kept chunk", 1, 0, 0, 0),
+		testDoc(page, "removed chunk", 0, 1, 0, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+
+	err = client.ReplaceByURL(ctx, page, []*crawler.CrawlVectorDoc{
+		testDoc(page, "kept chunk", 1, 0, 0, 0),
+		testDoc(page, "new chunk", 0, 0, 1, 0),
+	})
+	if err != nil {
+		t.Fatalf("ReplaceByURL: %v", err)
+	}
+	got := fake.urlPoints(page)
+	want := []string{pointID("kept chunk"), pointID("new chunk")}
+	if !sameIDs(got, want) {
+		t.Fatalf("points after replace = %v, want %v", got, want)
+	}
+
+	if err := client.ReplaceByURL(ctx, page, nil); err != nil {
+		t.Fatalf("ReplaceByURL with no chunks: %v", err)
+	}
+	if n := len(fake.urlPoints(page)); n != 0 {
+		t.Fatalf("%d points remain after replacing with no chunks, want 0", n)
+	}
+}
+
+func TestReplaceByURLKeepsPreviousVersionWhenInsertFails(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newCollection(t)
+	page := "https://a.example/page"
+
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc(page, "old chunk", 1, 0, 0, 0),
+		testDoc(page, "older chunk", 0, 1, 0, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+
+	fake.mu.Lock()
+	fake.upsertErr = errors.New("disk full")
+	fake.mu.Unlock()
+	err = client.ReplaceByURL(ctx, page, []*crawler.CrawlVectorDoc{
+		testDoc(page, "new chunk", 0, 0, 1, 0),
+	})
+	if err == nil {
+		t.Fatal("ReplaceByURL succeeded although the insert failed")
+	}
+	if n := len(fake.urlPoints(page)); n != 2 {
+		t.Fatalf("%d points remain after a failed replace, want the previous 2", n)
+	}
+}
+
+func sameIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	seen := make(map[string]int, len(got))
+	for _, id := range got {
+		seen[id]++
+	}
+	for _, id := range want {
+		if seen[id] == 0 {
+			return false
+		}
+		seen[id]--
+	}
+	return true
+}
+
+func insertChunks(t *testing.T, client *CrawlClient, n int) map[string]bool {
+	t.Helper()
+	docs := make([]*crawler.CrawlVectorDoc, 0, n)
+	contents := make(map[string]bool, n)
+	for i := range n {
+		content := fmt.Sprintf("chunk %d", i)
+		contents[content] = false
+		docs = append(docs, testDoc("https://a.example/page", content, float32(i+1), 1, 0, 0))
+	}
+	if err := client.InsertBatch(context.Background(), docs); err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+	return contents
+}
+
+func TestScrollFuncVisitsEveryPointOnce(t *testing.T) {
+	client, fake := newCollection(t)
+	contents := insertChunks(t, client, 7)
+
+	err := client.ScrollFunc(context.Background(), 3, func(doc *crawler.CrawlVectorDoc) error {
+		seen, ok := contents[doc.Content]
+		if !ok {
+			t.Errorf("unexpected chunk %q", doc.Content)
+		}
+		if seen {
+			t.Errorf("chunk %q visited twice", doc.Content)
+		}
+		if len(doc.ContentEmbedding) != testVectorSize {
+			t.Errorf("chunk %q has a %d-d vector", doc.Content, len(doc.ContentEmbedding))
+		}
+		contents[doc.Content] = true
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ScrollFunc: %v", err)
+	}
+	for content, seen := range contents {
+		if !seen {
+			t.Errorf("chunk %q was never visited", content)
+		}
+	}
+	if fake.scrollCalls != 3 {
+		t.Errorf("scrolled %d pages, want 3 for 7 points in batches of 3", fake.scrollCalls)
+	}
+}
+
+func TestScrollYieldsEveryPointOnce(t *testing.T) {
+	client, fake := newCollection(t)
+	contents := insertChunks(t, client, 7)
+
+	docs, err := client.Scroll(context.Background(), 3)
+	if err != nil {
+		t.Fatalf("Scroll: %v", err)
+	}
+	for doc := range docs {
+		seen, ok := contents[doc.Content]
+		if !ok {
+			t.Errorf("unexpected chunk %q", doc.Content)
+		}
+		if seen {
+			t.Errorf("chunk %q yielded twice", doc.Content)
+		}
+		if len(doc.ContentEmbedding) != testVectorSize {
+			t.Errorf("chunk %q has a %d-d vector", doc.Content, len(doc.ContentEmbedding))
+		}
+		contents[doc.Content] = true
+	}
+	for content, seen := range contents {
+		if !seen {
+			t.Errorf("chunk %q was never yielded", content)
+		}
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.scrollCalls != 3 {
+		t.Errorf("scrolled %d pages, want 3 for 7 points in batches of 3", fake.scrollCalls)
+	}
+}
+
+func TestScrollReturnsFirstPageError(t *testing.T) {
+	client, fake := newCollection(t)
+	insertChunks(t, client, 2)
+	fake.mu.Lock()
+	fake.failScroll = 1
+	fake.mu.Unlock()
+
+	if _, err := client.Scroll(context.Background(), 3); err == nil || !strings.Contains(err.Error(), "shard unavailable") {
+		t.Fatalf("Scroll error = %v, want the failure of the first page", err)
+	}
+	if _, err := client.Scroll(context.Background(), 0); err == nil {
+		t.Fatal("Scroll accepted a zero batch size")
+	}
+}
+
+func TestScrollClosesChannelOnCancel(t *testing.T) {
+	client, fake := newCollection(t)
+	insertChunks(t, client, 7)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	docs, err := client.Scroll(ctx, 1)
+	if err != nil {
+		t.Fatalf("Scroll: %v", err)
+	}
+	<-docs
+	cancel()
+
+	// the paging goroutine stops and closes the channel instead of blocking
+	// on a reader that is gone
+	done := make(chan struct{})
+	go func() {
+		for range docs {
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("channel was not closed after cancel")
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.scrollCalls >= 7 {
+		t.Fatalf("scrolled all %d pages despite the cancel", fake.scrollCalls)
+	}
+}
+
+func TestScrollFuncReturnsErrorOfLaterPage(t *testing.T) {
+	client, fake := newCollection(t)
+	insertChunks(t, client, 7)
+	fake.mu.Lock()
+	fake.failScroll = 2
+	fake.mu.Unlock()
+
+	visited := 0
+	err := client.ScrollFunc(context.Background(), 3, func(*crawler.CrawlVectorDoc) error {
+		visited++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "shard unavailable") {
+		t.Fatalf("ScrollFunc error = %v, want the failure of the second page", err)
+	}
+	if visited != 3 {
+		t.Fatalf("visited %d points before the failure, want the first page of 3", visited)
+	}
+}
+
+func TestScrollFuncStopsOnCallbackError(t *testing.T) {
+	client, _ := newCollection(t)
+	insertChunks(t, client, 4)
+	stop := errors.New("stop")
+
+	visited := 0
+	err := client.ScrollFunc(context.Background(), 10, func(*crawler.CrawlVectorDoc) error {
+		visited++
+		return stop
+	})
+	if !errors.Is(err, stop) || visited != 1 {
+		t.Fatalf("ScrollFunc = %v after %d points, want the callback error after 1", err, visited)
+	}
+	if err := client.ScrollFunc(context.Background(), 0, nil); err == nil {
+		t.Fatal("ScrollFunc accepted a zero batch size")
+	}
+}
+
+func TestSearchRanksNearestChunks(t *testing.T) {
+	ctx := context.Background()
+	client, _ := newCollection(t)
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc("https://a.example/x", "exact", 1, 0, 0, 0),
+		testDoc("https://a.example/y", "close", 1, 1, 0, 0),
+		testDoc("https://a.example/z", "orthogonal", 0, 0, 1, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+
+	hits, err := client.Search(ctx, []float32{1, 0, 0, 0}, 2)
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 || hits[0].Content != "exact" || hits[1].Content != "close" {
+		t.Fatalf("hits = %+v, want exact then close", hits)
+	}
+	first := hits[0]
+	if first.ID != pointID("exact") || first.URL != "https://a.example/x" || first.Title != "title of exact" {
+		t.Errorf("first hit = %+v, want the stored payload of the exact chunk", first)
+	}
+	if first.Score < 0.99 || hits[1].Score >= first.Score {
+		t.Errorf("scores = %v, %v, want about 1 then lower", first.Score, hits[1].Score)
+	}
+
+	hits, err = client.Search(ctx, []float32{1, 0, 0, 0}, 10, crawler.WithScoreThreshold(0.5))
+	if err != nil {
+		t.Fatalf("Search with threshold: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("%d hits above the threshold, want 2", len(hits))
+	}
+
+	if _, err := client.Search(ctx, []float32{1, 0, 0, 0}, 0); err == nil {
+		t.Fatal("Search accepted a zero topK")
+	}
+}
+
+func TestSearchFiltersByHost(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newCollection(t)
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc("https://a.example/1", "on a", 1, 0, 0, 0),
+		testDoc("https://b.example:8443/1", "on b", 1, 0, 0, 0),
+		testDoc("https://c.example/1", "on c", 1, 0, 0, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+	if !sameIDs(fake.indexes, []string{"host", "url"}) {
+		t.Fatalf("indexes = %v, want host and url", fake.indexes)
+	}
+
+	hits, err := client.Search(ctx, []float32{1, 0, 0, 0}, 10, crawler.WithHosts("a.example", "b.example"))
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	var got []string
+	for _, hit := range hits {
+		got = append(got, hit.Content)
+	}
+	if !sameIDs(got, []string{"on a", "on b"}) {
+		t.Fatalf("hits = %v, want the chunks of a.example and b.example", got)
+	}
+
+	hits, err = client.Search(ctx, []float32{1, 0, 0, 0}, 10, crawler.WithHosts())
+	if err != nil {
+		t.Fatalf("Search with no hosts: %v", err)
+	}
+	if len(hits) != 3 {
+		t.Fatalf("%d hits without a host filter, want all 3", len(hits))
+	}
+}
+
+func TestPayloadRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newCollection(t)
+	doc := testDoc("https://a.example/page?id=1", "stored chunk", 0.5, 0.25, 0, 1)
+	doc.CrawledAt = time.Date(2025, 3, 4, 5, 6, 7, 0, time.FixedZone("WIB", 7*3600))
+	if err := client.InsertOne(ctx, doc); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+
+	id := pointID(doc.Content)
+	point, err := client.GetPoint(ctx, id)
+	if err != nil {
+		t.Fatalf("GetPoint: %v", err)
+	}
+	got := point.CrawlVectorDoc
+	if point.ID != id || got.URL != doc.URL || got.Title != doc.Title || got.SiteName != doc.SiteName ||
+		got.Content != doc.Content || got.QualityScore != doc.QualityScore {
+		t.Fatalf("point = %+v, want the fields of %+v", point, doc)
+	}
+	if !got.CrawledAt.Equal(doc.CrawledAt) {
+		t.Errorf("crawled at = %s, want %s", got.CrawledAt, doc.CrawledAt)
+	}
+	if fmt.Sprint(got.ContentEmbedding) != fmt.Sprint(doc.ContentEmbedding) {
+		t.Errorf("vector = %v, want %v", got.ContentEmbedding, doc.ContentEmbedding)
+	}
+
+	fake.mu.Lock()
+	host := fake.points[id].GetPayload()["host"].GetStringValue()
+	fake.mu.Unlock()
+	if host != "a.example" {
+		t.Errorf("host payload = %q, want a.example", host)
+	}
+
+	if _, err := client.GetPoint(ctx, pointID("never stored")); !errors.Is(err, crawler.ErrPointNotFound) {
+		t.Errorf("GetPoint of an unknown id = %v, want ErrPointNotFound", err)
+	}
+	if _, err := client.GetPoint(ctx, "not-a-uuid"); !errors.Is(err, crawler.ErrPointNotFound) {
+		t.Errorf("GetPoint of an invalid id = %v, want ErrPointNotFound", err)
+	}
+}
+
+func TestInsertBatchSkipsDuplicatesInOneRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	client, fake := newCollection(t)
+	if err := client.InsertOne(ctx, testDoc("https://a.example/1", "already stored", 1, 0, 0, 0)); err != nil {
+		t.Fatalf("InsertOne: %v", err)
+	}
+	fake.mu.Lock()
+	fake.getCalls, fake.upserts = 0, nil
+	fake.mu.Unlock()
+
+	err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc("https://a.example/1", "already stored", 1, 0, 0, 0),
+		testDoc("https://a.example/2", "new chunk", 0, 1, 0, 0),
+		testDoc("https://a.example/3", "new chunk", 0, 1, 0, 0),
+		testDoc("https://a.example/2", "other chunk", 0, 0, 1, 0),
+	})
+	if err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+	if fake.getCalls != 1 || fmt.Sprint(fake.upserts) != "[2]" {
+		t.Fatalf("%d gets and upserts of %v, want one get and one upsert of the 2 new chunks",
+			fake.getCalls, fake.upserts)
+	}
+	if n := len(fake.ids()); n != 3 {
+		t.Fatalf("%d points stored, want 3", n)
+	}
+
+	// a batch of stored chunks only needs the lookup
+	if err := client.InsertBatch(ctx, []*crawler.CrawlVectorDoc{
+		testDoc("https://a.example/2", "new chunk", 0, 1, 0, 0),
+	}); err != nil {
+		t.Fatalf("InsertBatch of stored chunks: %v", err)
+	}
+	if fake.getCalls != 2 || len(fake.upserts) != 1 {
+		t.Fatalf("%d gets and %d upserts, want no upsert for stored chunks", fake.getCalls, len(fake.upserts))
+	}
+	if err := client.InsertBatch(ctx, nil); err != nil || fake.getCalls != 2 {
+		t.Fatalf("empty batch = %v after %d gets, want no round trip", err, fake.getCalls)
+	}
+}
+
+func TestPayloadContentModes(t *testing.T) {
+	ctx := context.Background()
+	content := "héllo wörld"
+	hash := sha256.Sum256([]byte(content))
+
+	tests := []struct {
+		name      string
+		payload   PayloadConfig
+		content   string // "" when page_content must not be stored
+		hash      string
+		truncated bool
+	}{
+		{"full", PayloadConfig{ContentMode: ContentFull}, content, "", false},
+		{"hash", PayloadConfig{ContentMode: ContentHash}, "", hex.EncodeToString(hash[:]), false},
+		// truncation counts runes, so multi-byte characters are kept whole
+		{"truncate", PayloadConfig{ContentMode: ContentTruncate, MaxContentLen: 4}, "héll", "", true},
+		{"under the limit", PayloadConfig{ContentMode: ContentTruncate, MaxContentLen: 100}, content, "", false},
+	}
+	for _, tt := range tests {
+		client, fake := newTestClient(t, testVectorSize, "cosine", tt.payload)
+		if err := client.CreateCollection(ctx); err != nil {
+			t.Fatalf("%s: CreateCollection: %v", tt.name, err)
+		}
+		if err := client.InsertOne(ctx, testDoc("https://a.example/page", content, 1, 0, 0, 0)); err != nil {
+			t.Fatalf("%s: InsertOne: %v", tt.name, err)
+		}
+
+		fake.mu.Lock()
+		payload := fake.points[pointID(content)].GetPayload()
+		fake.mu.Unlock()
+		stored, hasContent := payload["page_content"]
+		if tt.content == "" && hasContent {
+			t.Errorf("%s: page_content %q stored", tt.name, stored.GetStringValue())
+		}
+		if tt.content != "" && stored.GetStringValue() != tt.content {
+			t.Errorf("%s: page_content = %q, want %q", tt.name, stored.GetStringValue(), tt.content)
+		}
+		if got := payload["content_hash"].GetStringValue(); got != tt.hash {
+			t.Errorf("%s: content_hash = %q, want %q", tt.name, got, tt.hash)
+		}
+		if got := payload["content_truncated"].GetBoolValue(); got != tt.truncated {
+			t.Errorf("%s: content_truncated = %v, want %v", tt.name, got, tt.truncated)
+		}
+	}
+}
+
+func TestDenseVectorReadsBothEncodings(t *testing.T) {
+	dense := &qdrant.VectorOutput{Vector: &qdrant.VectorOutput_Dense{
+		Dense: &qdrant.DenseVector{Data: []float32{1, 2}},
+	}}
+	legacy := &qdrant.VectorOutput{Data: []float32{3, 4}}
+
+	if got := denseVector(dense); fmt.Sprint(got) != "[1 2]" {
+		t.Errorf("dense vector = %v, want [1 2]", got)
+	}
+	if got := denseVector(legacy); fmt.Sprint(got) != "[3 4]" {
+		t.Errorf("legacy vector = %v, want [3 4]", got)
+	}
+	if got := denseVector(nil); got != nil {
+		t.Errorf("missing vector = %v, want nil", got)
+	}
+}
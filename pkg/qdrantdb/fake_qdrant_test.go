@@ -0,0 +1,310 @@
+package qdrantdb
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/qdrant/go-client/qdrant"
+	"google.golang.org/grpc"
+)
+
+// fakeQdrant is an in-memory stand-in for the qdrant gRPC API, covering the
+// calls CrawlClient makes against a single collection
+type fakeQdrant struct {
+	mu         sync.Mutex
+	collection *qdrant.VectorParams // nil until the collection is created
+	points     map[string]*qdrant.PointStruct
+	indexes    []string
+
+	upsertErr   error
+	getCalls    int
+	upserts     []int // points per Upsert call
+	scrollCalls int
+	failScroll  int // fail this scroll call, counting from 1, 0 never fails
+}
+
+// newTestClient serves a fakeQdrant on a local port and connects a
+// CrawlClient to it
+func newTestClient(t *testing.T, vectorSize int, distance string, payload PayloadConfig) (*CrawlClient, *fakeQdrant) {
+	t.Helper()
+
+	fake := &fakeQdrant{points: make(map[string]*qdrant.PointStruct)}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := grpc.NewServer()
+	qdrant.RegisterQdrantServer(srv, fakeHealth{})
+	qdrant.RegisterCollectionsServer(srv, fakeCollections{fakeQdrant: fake})
+	qdrant.RegisterPointsServer(srv, fakePoints{fakeQdrant: fake})
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	addr := lis.Addr().(*net.TCPAddr)
+	client, err := NewClient(addr.IP.String(), addr.Port, vectorSize, distance, payload)
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Client.Close() })
+	return client, fake
+}
+
+// ids returns the stored point IDs in scroll order
+func (f *fakeQdrant) ids() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.sortedIDs()
+}
+
+func (f *fakeQdrant) sortedIDs() []string {
+	ids := make([]string, 0, len(f.points))
+	for id := range f.points {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (f *fakeQdrant) matches(filter *qdrant.Filter, p *qdrant.PointStruct) bool {
+	for _, c := range filter.GetMust() {
+		if !matchCondition(c, p) {
+			return false
+		}
+	}
+	for _, c := range filter.GetMustNot() {
+		if matchCondition(c, p) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchCondition(c *qdrant.Condition, p *qdrant.PointStruct) bool {
+	if hasID := c.GetHasId(); hasID != nil {
+		for _, id := range hasID.GetHasId() {
+			if id.GetUuid() == p.GetId().GetUuid() {
+				return true
+			}
+		}
+		return false
+	}
+	field := c.GetField()
+	value := p.GetPayload()[field.GetKey()].GetStringValue()
+	match := field.GetMatch()
+	if keywords := match.GetKeywords(); keywords != nil {
+		for _, k := range keywords.GetStrings() {
+			if k == value {
+				return true
+			}
+		}
+		return false
+	}
+	return match.GetKeyword() == value
+}
+
+// retrieved returns p the way current qdrant servers do, with the vector
+// in the dense oneof
+func (f *fakeQdrant) retrieved(p *qdrant.PointStruct) *qdrant.RetrievedPoint {
+	return &qdrant.RetrievedPoint{
+		Id:      p.GetId(),
+		Payload: p.GetPayload(),
+		Vectors: vectorsOutput(&qdrant.VectorOutput{Vector: &qdrant.VectorOutput_Dense{
+			Dense: &qdrant.DenseVector{Data: denseData(p.GetVectors().GetVector())},
+		}}),
+	}
+}
+
+func vectorsOutput(v *qdrant.VectorOutput) *qdrant.VectorsOutput {
+	return &qdrant.VectorsOutput{VectorsOptions: &qdrant.VectorsOutput_Vector{Vector: v}}
+}
+
+// denseData reads a dense vector whether the client sent it in the dense
+// field or the older data field
+func denseData(v *qdrant.Vector) []float32 {
+	if dense := v.GetDense(); dense != nil {
+		return dense.GetData()
+	}
+	return v.GetData()
+}
+
+// score mirrors qdrant: similarities for cosine and dot, distances for
+// euclid and manhattan
+func score(distance qdrant.Distance, a, b []float32) float32 {
+	var dot, na, nb, l1, l2 float64
+	for i := range a {
+		x, y := float64(a[i]), float64(b[i])
+		dot += x * y
+		na += x * x
+		nb += y * y
+		l1 += math.Abs(x - y)
+		l2 += (x - y) * (x - y)
+	}
+	switch distance {
+	case qdrant.Distance_Dot:
+		return float32(dot)
+	case qdrant.Distance_Euclid:
+		return float32(math.Sqrt(l2))
+	case qdrant.Distance_Manhattan:
+		return float32(l1)
+	default:
+		return float32(dot / (math.Sqrt(na) * math.Sqrt(nb)))
+	}
+}
+
+func isDistance(distance qdrant.Distance) bool {
+	return distance == qdrant.Distance_Euclid || distance == qdrant.Distance_Manhattan
+}
+
+type fakeHealth struct {
+	qdrant.UnimplementedQdrantServer
+}
+
+func (fakeHealth) HealthCheck(context.Context, *qdrant.HealthCheckRequest) (*qdrant.HealthCheckReply, error) {
+	return &qdrant.HealthCheckReply{Title: "fake", Version: "1.16.0"}, nil
+}
+
+type fakeCollections struct {
+	qdrant.UnimplementedCollectionsServer
+	*fakeQdrant
+}
+
+func (f fakeCollections) CollectionExists(context.Context, *qdrant.CollectionExistsRequest) (*qdrant.CollectionExistsResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &qdrant.CollectionExistsResponse{
+		Result: &qdrant.CollectionExists{Exists: f.collection != nil},
+	}, nil
+}
+
+func (f fakeCollections) Create(_ context.Context, req *qdrant.CreateCollection) (*qdrant.CollectionOperationResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.collection = req.GetVectorsConfig().GetParams()
+	return &qdrant.CollectionOperationResponse{Result: true}, nil
+}
+
+func (f fakeCollections) Get(context.Context, *qdrant.GetCollectionInfoRequest) (*qdrant.GetCollectionInfoResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.collection == nil {
+		return nil, errors.New("collection not found")
+	}
+	return &qdrant.GetCollectionInfoResponse{Result: &qdrant.CollectionInfo{
+		Config: &qdrant.CollectionConfig{Params: &qdrant.CollectionParams{
+			VectorsConfig: qdrant.NewVectorsConfig(f.collection),
+		}},
+	}}, nil
+}
+
+type fakePoints struct {
+	qdrant.UnimplementedPointsServer
+	*fakeQdrant
+}
+
+func (f fakePoints) CreateFieldIndex(_ context.Context, req *qdrant.CreateFieldIndexCollection) (*qdrant.PointsOperationResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.indexes = append(f.indexes, req.GetFieldName())
+	return &qdrant.PointsOperationResponse{Result: &qdrant.UpdateResult{}}, nil
+}
+
+func (f fakePoints) Upsert(_ context.Context, req *qdrant.UpsertPoints) (*qdrant.PointsOperationResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.upsertErr != nil {
+		return nil, f.upsertErr
+	}
+	f.upserts = append(f.upserts, len(req.GetPoints()))
+	for _, p := range req.GetPoints() {
+		f.points[p.GetId().GetUuid()] = p
+	}
+	return &qdrant.PointsOperationResponse{Result: &qdrant.UpdateResult{}}, nil
+}
+
+func (f fakePoints) Delete(_ context.Context, req *qdrant.DeletePoints) (*qdrant.PointsOperationResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	filter := req.GetPoints().GetFilter()
+	for id, p := range f.points {
+		if f.matches(filter, p) {
+			delete(f.points, id)
+		}
+	}
+	return &qdrant.PointsOperationResponse{Result: &qdrant.UpdateResult{}}, nil
+}
+
+func (f fakePoints) Get(_ context.Context, req *qdrant.GetPoints) (*qdrant.GetResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getCalls++
+	var result []*qdrant.RetrievedPoint
+	for _, id := range req.GetIds() {
+		if p, ok := f.points[id.GetUuid()]; ok {
+			result = append(result, f.retrieved(p))
+		}
+	}
+	return &qdrant.GetResponse{Result: result}, nil
+}
+
+func (f fakePoints) Query(_ context.Context, req *qdrant.QueryPoints) (*qdrant.QueryResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	vector := req.GetQuery().GetNearest().GetDense().GetData()
+	distance := f.collection.GetDistance()
+
+	var result []*qdrant.ScoredPoint
+	for _, id := range f.sortedIDs() {
+		p := f.points[id]
+		if !f.matches(req.GetFilter(), p) {
+			continue
+		}
+		s := score(distance, vector, denseData(p.GetVectors().GetVector()))
+		if t := req.ScoreThreshold; t != nil {
+			if isDistance(distance) && s > *t || !isDistance(distance) && s < *t {
+				continue
+			}
+		}
+		result = append(result, &qdrant.ScoredPoint{Id: p.GetId(), Payload: p.GetPayload(), Score: s})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if isDistance(distance) {
+			return result[i].Score < result[j].Score
+		}
+		return result[i].Score > result[j].Score
+	})
+	if limit := int(req.GetLimit()); limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return &qdrant.QueryResponse{Result: result}, nil
+}
+
+func (f fakePoints) Scroll(_ context.Context, req *qdrant.ScrollPoints) (*qdrant.ScrollResponse, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.scrollCalls++
+	if f.scrollCalls == f.failScroll {
+		return nil, errors.New("shard unavailable")
+	}
+
+	ids := f.sortedIDs()
+	start := 0
+	if offset := req.GetOffset().GetUuid(); offset != "" {
+		start = sort.SearchStrings(ids, offset)
+	}
+	end := start + int(req.GetLimit())
+	resp := &qdrant.ScrollResponse{}
+	if end < len(ids) {
+		resp.NextPageOffset = qdrant.NewID(ids[end])
+	} else {
+		end = len(ids)
+	}
+	for _, id := range ids[start:end] {
+		resp.Result = append(resp.Result, f.retrieved(f.points[id]))
+	}
+	return resp, nil
+}
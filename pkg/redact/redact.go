@@ -0,0 +1,60 @@
+package redact
+
+import (
+	"net/url"
+	"strings"
+)
+
+const mask = "REDACTED"
+
+// sensitiveParams are query parameters whose values grant access on their own,
+// such as the download key in libgen's get.php?md5=...&key=...
+var sensitiveParams = map[string]struct{}{
+	"key":          {},
+	"api_key":      {},
+	"apikey":       {},
+	"token":        {},
+	"access_token": {},
+	"password":     {},
+	"secret":       {},
+}
+
+// URL masks the userinfo password and the values of sensitive query
+// parameters in raw. Unparseable input is masked whole, as there is no telling
+// which part of it is a secret
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return mask
+	}
+
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), mask)
+	}
+	u.RawQuery = query(u.RawQuery)
+	return u.String()
+}
+
+// query masks sensitive values pair by pair on the raw query, since
+// url.ParseQuery drops pairs with bad escapes that would then be logged as is.
+// Other pairs keep their original encoding and order
+func query(rawQuery string) string {
+	if rawQuery == "" {
+		return rawQuery
+	}
+	pairs := strings.Split(rawQuery, "&")
+	for i, pair := range pairs {
+		rawName, _, hasValue := strings.Cut(pair, "=")
+		if !hasValue {
+			continue
+		}
+		name := rawName
+		if unescaped, err := url.QueryUnescape(rawName); err == nil {
+			name = unescaped
+		}
+		if _, ok := sensitiveParams[strings.ToLower(name)]; ok {
+			pairs[i] = rawName + "=" + mask
+		}
+	}
+	return strings.Join(pairs, "&")
+}